@@ -1,24 +1,106 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/explain"
 	"github.com/jwswj/shell-ai/internal/llm"
+	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/shellinit"
 	"github.com/jwswj/shell-ai/internal/suggestions"
 )
 
 var CLI struct {
-	Debug  bool     `help:"Enable debug mode" env:"DEBUG"`
-	Ctx    bool     `help:"Set context mode to True" env:"CTX"`
-	Prompt []string `arg:"" optional:"" help:"The prompt to generate shell commands for"`
+	Debug bool `help:"Enable debug mode" env:"DEBUG"`
+	Ctx   bool `help:"Set context mode to True" env:"CTX"`
+
+	Suggest struct {
+		Print  bool     `help:"Print the suggested commands one per line instead of opening the menu"`
+		JSON   bool     `name:"json" help:"Print the suggestions and their metadata as JSON instead of opening the menu"`
+		First  bool     `help:"Print only the first suggestion instead of opening the menu"`
+		Select bool     `help:"Pick a suggestion from a menu on stderr and print it instead of running it"`
+		Prompt []string `arg:"" optional:"" help:"The prompt to generate shell commands for"`
+	} `cmd:"" default:"withargs" help:"Suggest shell commands for a prompt (default), use 'shai suggest <prompt>' when the prompt starts with explain or init"`
+
+	Explain struct {
+		Command []string `arg:"" passthrough:"" help:"The command to explain, quoted or as is"`
+	} `cmd:"" help:"Explain an existing shell command part by part"`
+
+	Init struct {
+		Shell string `arg:"" enum:"zsh,bash,fish" help:"The shell to integrate with (zsh, bash or fish)"`
+	} `cmd:"" help:"Print a script that binds Ctrl+G to place a picked suggestion in the shell's line editor"`
+}
+
+// Exit codes of non-interactive runs
+const (
+	exitOK            = 0
+	exitError         = 1
+	exitNoSuggestions = 2
+	exitLLMError      = 3
+	exitInterrupted   = 130
+)
+
+// exitCode returns the exit code for the result of a non-interactive run
+func exitCode(err error) int {
+	var apiErr *llm.APIError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, suggestions.ErrNoSuggestions), errors.Is(err, parser.ErrNoCommand):
+		return exitNoSuggestions
+	case errors.As(err, &apiErr):
+		return exitLLMError
+	default:
+		return exitError
+	}
+}
+
+// suggestArgs returns the arguments with the suggest command made explicit
+// when a prompt starts with the name of another command, such as "init a new
+// git repo", or follows "--". Kong would otherwise parse it as that command.
+func suggestArgs(args []string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			return append([]string{"suggest"}, args...)
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		// A real init has a single shell, explain takes any command so only a
+		// prompt after "--" or "suggest" is known not to be one
+		if arg == "init" && len(args) > i+1 && (len(args) > i+2 || !slices.Contains(shellinit.Shells, args[i+1])) {
+			return append([]string{"suggest"}, args...)
+		}
+		return args
+	}
+	return args
 }
 
 func main() {
-	ctx := kong.Parse(&CLI)
+	app := kong.Must(&CLI)
+	ctx, err := app.Parse(suggestArgs(os.Args[1:]))
+	app.FatalIfErrorf(err)
+
+	// Shell integration scripts need no configuration
+	if ctx.Command() == "init <shell>" {
+		script, err := shellinit.Script(CLI.Init.Shell)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(script)
+		return
+	}
 
 	// Load configuration
 	cfg, err := config.LoadConfig()
@@ -37,30 +119,55 @@ func main() {
 		cfg.ContextMode = true
 	}
 
-	// Check if API keys are set
-	if cfg.OpenAIAPIKey == "" && cfg.GroqAPIKey == "" {
-		fmt.Println("Please set either the OPENAI_API_KEY or GROQ_API_KEY environment variable.")
-		fmt.Println("You can also create `config.json` under `~/.config/shell-ai/` to set the API key, see README.md for more information.")
-		os.Exit(1)
-	}
-
 	// Create LLM client based on configuration
 	client, err := llm.NewClient(cfg)
 	if err != nil {
+		// Check if the provider needs an API key, local providers do not
+		var keyErr *llm.MissingAPIKeyError
+		if errors.As(err, &keyErr) {
+			fmt.Fprintf(os.Stderr, "Please set the %s environment variable.\n", keyErr.EnvVar)
+			fmt.Fprintln(os.Stderr, "You can also create `config.json` under `~/.config/shell-ai/` to set the API key, see README.md for more information.")
+			os.Exit(1)
+		}
+
 		fmt.Fprintf(os.Stderr, "Error creating LLM client: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Run the command
 	switch ctx.Command() {
+	case "explain <command>":
+		err = explain.Run(context.Background(), client, cfg, strings.Join(CLI.Explain.Command, " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error explaining command: %v\n", err)
+			os.Exit(1)
+		}
 	default:
-		if len(CLI.Prompt) == 0 {
+		nonInteractive := CLI.Suggest.Print || CLI.Suggest.JSON || CLI.Suggest.First || CLI.Suggest.Select
+		if len(CLI.Suggest.Prompt) == 0 {
+			if nonInteractive {
+				fmt.Fprintln(os.Stderr, "Describe what you want to do as a single sentence. `shai --print <sentence>`")
+				os.Exit(exitError)
+			}
 			fmt.Println("Describe what you want to do as a single sentence. `shai <sentence>`")
 			os.Exit(0)
 		}
 
+		// Print the suggestions without opening the menu
+		if nonInteractive {
+			opts := suggestions.PrintOptions{First: CLI.Suggest.First, Select: CLI.Suggest.Select}
+			if CLI.Suggest.JSON {
+				opts.Format = suggestions.OutputJSON
+			}
+			err = suggestions.Print(context.Background(), client, cfg, CLI.Suggest.Prompt, os.Stdout, opts)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(os.Stderr, "Error generating suggestions: %v\n", err)
+			}
+			os.Exit(exitCode(err))
+		}
+
 		// Run the suggestions engine
-		err = suggestions.Run(client, cfg, CLI.Prompt)
+		err = suggestions.Run(context.Background(), client, cfg, CLI.Suggest.Prompt)
 		if err != nil {
 			// Check if the error is due to Ctrl+C (interrupt)
 			if err.Error() == "^C" || strings.Contains(err.Error(), "interrupt") {
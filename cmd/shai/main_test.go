@@ -0,0 +1,30 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSuggestArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{args: "find large files", want: "find large files"},
+		{args: "init zsh", want: "init zsh"},
+		{args: "init", want: "init"},
+		{args: "init a new git repo in this folder", want: "suggest init a new git repo in this folder"},
+		{args: "--print init git", want: "suggest --print init git"},
+		{args: "--print --select -- init a new git repo", want: "suggest --print --select -- init a new git repo"},
+		{args: "explain tar -xzvf archive.tar.gz", want: "explain tar -xzvf archive.tar.gz"},
+		{args: "suggest explain this error", want: "suggest explain this error"},
+	}
+
+	for _, tt := range tests {
+		got := suggestArgs(strings.Fields(tt.args))
+		if want := strings.Fields(tt.want); !slices.Equal(got, want) {
+			t.Errorf("suggestArgs(%q) = %q, want %q", tt.args, got, want)
+		}
+	}
+}
@@ -2,10 +2,17 @@ module github.com/jwswj/shell-ai
 
 go 1.22.5
 
-require github.com/alecthomas/kong v1.9.0
+require (
+	github.com/alecthomas/kong v1.9.0
+	github.com/creack/pty v1.1.24
+	github.com/manifoldco/promptui v0.9.0
+	golang.org/x/net v0.12.0
+	golang.org/x/sys v0.10.0
+	golang.org/x/term v0.10.0
+	mvdan.cc/sh/v3 v3.7.0
+)
 
 require (
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
-	github.com/manifoldco/promptui v0.9.0 // indirect
-	golang.org/x/sys v0.0.0-20181122145206-62eef0e2fa9b // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
@@ -0,0 +1,43 @@
+// Package cache keeps JSON files in the user's cache directory, for results
+// that are slow to compute and change rarely.
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Path returns the path of a cache file, or empty if the user has no cache
+// directory
+func Path(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shell-ai", name)
+}
+
+// Load decodes a cache file into v
+func Load(path string, v any) error {
+	if path == "" {
+		return os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save encodes v into a cache file, creating its directory
+func Save(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	type entry struct {
+		Name string `json:"name"`
+	}
+	path := filepath.Join(t.TempDir(), "shell-ai", "entry.json")
+
+	var got entry
+	if err := Load(path, &got); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load() error = %v, want a missing cache", err)
+	}
+	if err := Load("", &got); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load(\"\") error = %v, want a missing cache", err)
+	}
+
+	if err := Save(path, entry{Name: "rg"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := Load(path, &got); err != nil || got.Name != "rg" {
+		t.Errorf("Load() = %+v, %v, want the saved entry", got, err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	path := Path("tools.json")
+	if filepath.Base(path) != "tools.json" || filepath.Base(filepath.Dir(path)) != "shell-ai" {
+		t.Errorf("Path() = %q, want tools.json in a shell-ai directory", path)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 )
 
 // Config holds the application configuration
@@ -20,30 +21,74 @@ type Config struct {
 	OpenAIProxy        string `json:"OPENAI_PROXY"`
 	OpenAIAPIVersion   string `json:"OPENAI_API_VERSION"`
 
+	// TLS configuration for outbound LLM traffic
+	CABundle   string `json:"SHAI_CA_BUNDLE"`
+	ClientCert string `json:"SHAI_CLIENT_CERT"`
+	ClientKey  string `json:"SHAI_CLIENT_KEY"`
+
+	// Azure OpenAI configuration, the API key, base URL and API version are
+	// shared with OpenAI
+	AzureDeployment string `json:"AZURE_OPENAI_DEPLOYMENT"`
+
 	// Groq configuration
 	GroqAPIKey string `json:"GROQ_API_KEY"`
 	GroqModel  string `json:"GROQ_MODEL"`
 
+	// Anthropic configuration
+	AnthropicAPIKey    string `json:"ANTHROPIC_API_KEY"`
+	AnthropicModel     string `json:"ANTHROPIC_MODEL"`
+	AnthropicMaxTokens int    `json:"ANTHROPIC_MAX_TOKENS"`
+
+	// Ollama configuration
+	OllamaHost  string `json:"OLLAMA_HOST"`
+	OllamaModel string `json:"OLLAMA_MODEL"`
+
+	// Local OpenAI compatible server configuration, e.g. llama.cpp
+	LocalAPIBase string `json:"LOCAL_API_BASE"`
+	LocalAPIKey  string `json:"LOCAL_API_KEY"`
+	LocalModel   string `json:"LOCAL_MODEL"`
+
 	// Application configuration
-	APIProvider     string  `json:"SHAI_API_PROVIDER"`
-	SuggestionCount int     `json:"SHAI_SUGGESTION_COUNT"`
-	SkipConfirm     bool    `json:"SHAI_SKIP_CONFIRM"`
-	SkipHistory     bool    `json:"SHAI_SKIP_HISTORY"`
-	Temperature     float64 `json:"SHAI_TEMPERATURE"`
-	Debug           bool    `json:"DEBUG"`
-	ContextMode     bool    `json:"CTX"`
+	APIProvider      string  `json:"SHAI_API_PROVIDER"`
+	SuggestionCount  int     `json:"SHAI_SUGGESTION_COUNT"`
+	SkipConfirm      bool    `json:"SHAI_SKIP_CONFIRM"`
+	SkipHistory      bool    `json:"SHAI_SKIP_HISTORY"`
+	Temperature      float64 `json:"SHAI_TEMPERATURE"`
+	Stream           bool    `json:"SHAI_STREAM"`
+	BatchSuggestions bool    `json:"SHAI_BATCH_SUGGESTIONS"`
+	StructuredOutput bool    `json:"SHAI_STRUCTURED_OUTPUT"`
+	MaxAttempts      int     `json:"SHAI_MAX_ATTEMPTS"`
+	ConnectTimeout   int     `json:"SHAI_CONNECT_TIMEOUT"`
+	ResponseTimeout  int     `json:"SHAI_RESPONSE_TIMEOUT"`
+	DenyList         string  `json:"SHAI_DENY_LIST"`
+	AllowList        string  `json:"SHAI_ALLOW_LIST"`
+	SourceRC         bool    `json:"SHAI_SOURCE_RC"`
+	ContextTokens    int     `json:"SHAI_CONTEXT_TOKENS"`
+	Debug            bool    `json:"DEBUG"`
+	ContextMode      bool    `json:"CTX"`
 }
 
 // LoadConfig loads the configuration from environment variables and config file
 func LoadConfig() (*Config, error) {
 	// Create a new config with default values
 	cfg := &Config{
-		OpenAIModel:      "gpt-3.5-turbo",
-		SuggestionCount:  3,
-		APIProvider:      "groq",
-		GroqModel:        "llama-3.3-70b-versatile",
-		Temperature:      0.05,
-		OpenAIAPIVersion: "2023-05-15",
+		OpenAIModel:        "gpt-3.5-turbo",
+		SuggestionCount:    3,
+		APIProvider:        "groq",
+		GroqModel:          "llama-3.3-70b-versatile",
+		AnthropicModel:     "claude-3-5-haiku-latest",
+		AnthropicMaxTokens: 1024,
+		OllamaHost:         "http://localhost:11434",
+		OllamaModel:        "llama3.2",
+		LocalAPIBase:       "http://localhost:8080",
+		Temperature:        0.05,
+		Stream:             true,
+		BatchSuggestions:   true,
+		StructuredOutput:   true,
+		MaxAttempts:        3,
+		ConnectTimeout:     10,
+		ResponseTimeout:    60,
+		OpenAIAPIVersion:   "2023-05-15",
 	}
 
 	// Load from config file (overrides defaults)
@@ -112,12 +157,50 @@ func loadFromConfigFile(cfg *Config) error {
 	if val, ok := configMap["OPENAI_API_VERSION"]; ok {
 		cfg.OpenAIAPIVersion = val
 	}
+	if val, ok := configMap["SHAI_CA_BUNDLE"]; ok {
+		cfg.CABundle = val
+	}
+	if val, ok := configMap["SHAI_CLIENT_CERT"]; ok {
+		cfg.ClientCert = val
+	}
+	if val, ok := configMap["SHAI_CLIENT_KEY"]; ok {
+		cfg.ClientKey = val
+	}
+	if val, ok := configMap["AZURE_OPENAI_DEPLOYMENT"]; ok {
+		cfg.AzureDeployment = val
+	}
 	if val, ok := configMap["GROQ_API_KEY"]; ok {
 		cfg.GroqAPIKey = val
 	}
 	if val, ok := configMap["GROQ_MODEL"]; ok {
 		cfg.GroqModel = val
 	}
+	if val, ok := configMap["ANTHROPIC_API_KEY"]; ok {
+		cfg.AnthropicAPIKey = val
+	}
+	if val, ok := configMap["ANTHROPIC_MODEL"]; ok {
+		cfg.AnthropicModel = val
+	}
+	if val, ok := configMap["ANTHROPIC_MAX_TOKENS"]; ok {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.AnthropicMaxTokens = i
+		}
+	}
+	if val, ok := configMap["OLLAMA_HOST"]; ok {
+		cfg.OllamaHost = val
+	}
+	if val, ok := configMap["OLLAMA_MODEL"]; ok {
+		cfg.OllamaModel = val
+	}
+	if val, ok := configMap["LOCAL_API_BASE"]; ok {
+		cfg.LocalAPIBase = val
+	}
+	if val, ok := configMap["LOCAL_API_KEY"]; ok {
+		cfg.LocalAPIKey = val
+	}
+	if val, ok := configMap["LOCAL_MODEL"]; ok {
+		cfg.LocalModel = val
+	}
 	if val, ok := configMap["SHAI_API_PROVIDER"]; ok {
 		cfg.APIProvider = val
 	}
@@ -141,6 +224,52 @@ func loadFromConfigFile(cfg *Config) error {
 			cfg.Temperature = f
 		}
 	}
+	if val, ok := configMap["SHAI_STREAM"]; ok {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.Stream = b
+		}
+	}
+	if val, ok := configMap["SHAI_BATCH_SUGGESTIONS"]; ok {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.BatchSuggestions = b
+		}
+	}
+	if val, ok := configMap["SHAI_STRUCTURED_OUTPUT"]; ok {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.StructuredOutput = b
+		}
+	}
+	if val, ok := configMap["SHAI_MAX_ATTEMPTS"]; ok {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.MaxAttempts = i
+		}
+	}
+	if val, ok := configMap["SHAI_CONNECT_TIMEOUT"]; ok {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.ConnectTimeout = i
+		}
+	}
+	if val, ok := configMap["SHAI_RESPONSE_TIMEOUT"]; ok {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.ResponseTimeout = i
+		}
+	}
+	if val, ok := configMap["SHAI_DENY_LIST"]; ok {
+		cfg.DenyList = val
+	}
+	if val, ok := configMap["SHAI_ALLOW_LIST"]; ok {
+		cfg.AllowList = val
+	}
+	if val, ok := configMap["SHAI_SOURCE_RC"]; ok {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.SourceRC = b
+		}
+	}
+	if val, ok := configMap["SHAI_CONTEXT_TOKENS"]; ok {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.ContextTokens = i
+		}
+	}
 	if val, ok := configMap["DEBUG"]; ok {
 		if b, err := strconv.ParseBool(val); err == nil {
 			cfg.Debug = b
@@ -181,12 +310,50 @@ func loadFromEnv(cfg *Config) error {
 	if val := os.Getenv("OPENAI_API_VERSION"); val != "" {
 		cfg.OpenAIAPIVersion = val
 	}
+	if val := os.Getenv("SHAI_CA_BUNDLE"); val != "" {
+		cfg.CABundle = val
+	}
+	if val := os.Getenv("SHAI_CLIENT_CERT"); val != "" {
+		cfg.ClientCert = val
+	}
+	if val := os.Getenv("SHAI_CLIENT_KEY"); val != "" {
+		cfg.ClientKey = val
+	}
+	if val := os.Getenv("AZURE_OPENAI_DEPLOYMENT"); val != "" {
+		cfg.AzureDeployment = val
+	}
 	if val := os.Getenv("GROQ_API_KEY"); val != "" {
 		cfg.GroqAPIKey = val
 	}
 	if val := os.Getenv("GROQ_MODEL"); val != "" {
 		cfg.GroqModel = val
 	}
+	if val := os.Getenv("ANTHROPIC_API_KEY"); val != "" {
+		cfg.AnthropicAPIKey = val
+	}
+	if val := os.Getenv("ANTHROPIC_MODEL"); val != "" {
+		cfg.AnthropicModel = val
+	}
+	if val := os.Getenv("ANTHROPIC_MAX_TOKENS"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.AnthropicMaxTokens = i
+		}
+	}
+	if val := os.Getenv("OLLAMA_HOST"); val != "" {
+		cfg.OllamaHost = val
+	}
+	if val := os.Getenv("OLLAMA_MODEL"); val != "" {
+		cfg.OllamaModel = val
+	}
+	if val := os.Getenv("LOCAL_API_BASE"); val != "" {
+		cfg.LocalAPIBase = val
+	}
+	if val := os.Getenv("LOCAL_API_KEY"); val != "" {
+		cfg.LocalAPIKey = val
+	}
+	if val := os.Getenv("LOCAL_MODEL"); val != "" {
+		cfg.LocalModel = val
+	}
 	if val := os.Getenv("SHAI_API_PROVIDER"); val != "" {
 		cfg.APIProvider = val
 	}
@@ -210,6 +377,52 @@ func loadFromEnv(cfg *Config) error {
 			cfg.Temperature = f
 		}
 	}
+	if val := os.Getenv("SHAI_STREAM"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.Stream = b
+		}
+	}
+	if val := os.Getenv("SHAI_BATCH_SUGGESTIONS"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.BatchSuggestions = b
+		}
+	}
+	if val := os.Getenv("SHAI_STRUCTURED_OUTPUT"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.StructuredOutput = b
+		}
+	}
+	if val := os.Getenv("SHAI_MAX_ATTEMPTS"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.MaxAttempts = i
+		}
+	}
+	if val := os.Getenv("SHAI_CONNECT_TIMEOUT"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.ConnectTimeout = i
+		}
+	}
+	if val := os.Getenv("SHAI_RESPONSE_TIMEOUT"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.ResponseTimeout = i
+		}
+	}
+	if val := os.Getenv("SHAI_DENY_LIST"); val != "" {
+		cfg.DenyList = val
+	}
+	if val := os.Getenv("SHAI_ALLOW_LIST"); val != "" {
+		cfg.AllowList = val
+	}
+	if val := os.Getenv("SHAI_SOURCE_RC"); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			cfg.SourceRC = b
+		}
+	}
+	if val := os.Getenv("SHAI_CONTEXT_TOKENS"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			cfg.ContextTokens = i
+		}
+	}
 	if val := os.Getenv("DEBUG"); val != "" {
 		if b, err := strconv.ParseBool(val); err == nil {
 			cfg.Debug = b
@@ -224,9 +437,63 @@ func loadFromEnv(cfg *Config) error {
 	return nil
 }
 
-// DebugPrint prints debug information if debug mode is enabled
+// ProviderChain returns the providers listed in SHAI_API_PROVIDER in the order
+// they should be tried
+func (c *Config) ProviderChain() []string {
+	return splitList(c.APIProvider)
+}
+
+// Model returns the model of the first provider in SHAI_API_PROVIDER, or
+// empty if the provider is unknown
+func (c *Config) Model() string {
+	chain := c.ProviderChain()
+	if len(chain) == 0 {
+		return ""
+	}
+
+	switch chain[0] {
+	case "openai", "azure":
+		return c.OpenAIModel
+	case "groq":
+		return c.GroqModel
+	case "anthropic":
+		return c.AnthropicModel
+	case "ollama":
+		return c.OllamaModel
+	case "local":
+		return c.LocalModel
+	default:
+		return ""
+	}
+}
+
+// DenyPatterns returns the commands listed in SHAI_DENY_LIST, which are never
+// run
+func (c *Config) DenyPatterns() []string {
+	return splitList(c.DenyList)
+}
+
+// AllowPatterns returns the commands listed in SHAI_ALLOW_LIST, which are run
+// without a typed confirmation even if they are high risk
+func (c *Config) AllowPatterns() []string {
+	return splitList(c.AllowList)
+}
+
+// splitList splits a comma separated setting into its non empty entries
+func splitList(s string) []string {
+	var entries []string
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// DebugPrint prints debug information to stderr if debug mode is enabled, so it
+// does not mix with printed suggestions
 func (c *Config) DebugPrint(format string, args ...interface{}) {
 	if c.Debug {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(os.Stderr, format, args...)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -238,4 +239,83 @@ func TestDefaultValues(t *testing.T) {
 	if cfg.OpenAIAPIVersion != "2023-05-15" {
 		t.Errorf("Default OpenAIAPIVersion not set correctly, got: %s, want: %s", cfg.OpenAIAPIVersion, "2023-05-15")
 	}
+
+	if cfg.OllamaHost != "http://localhost:11434" {
+		t.Errorf("Default OllamaHost not set correctly, got: %s, want: %s", cfg.OllamaHost, "http://localhost:11434")
+	}
+
+	if !cfg.Stream {
+		t.Errorf("Default Stream not set correctly, got: %t, want: %t", cfg.Stream, true)
+	}
+
+	if !cfg.StructuredOutput {
+		t.Errorf("Default StructuredOutput not set correctly, got: %t, want: %t", cfg.StructuredOutput, true)
+	}
+
+	if cfg.MaxAttempts != 3 {
+		t.Errorf("Default MaxAttempts not set correctly, got: %d, want: %d", cfg.MaxAttempts, 3)
+	}
+
+	if cfg.ConnectTimeout != 10 {
+		t.Errorf("Default ConnectTimeout not set correctly, got: %d, want: %d", cfg.ConnectTimeout, 10)
+	}
+
+	if cfg.ResponseTimeout != 60 {
+		t.Errorf("Default ResponseTimeout not set correctly, got: %d, want: %d", cfg.ResponseTimeout, 60)
+	}
+
+	if !cfg.BatchSuggestions {
+		t.Errorf("Default BatchSuggestions not set correctly, got: %t, want: %t", cfg.BatchSuggestions, true)
+	}
+}
+
+func TestProviderChain(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     []string
+	}{
+		{provider: "groq", want: []string{"groq"}},
+		{provider: "groq,openai", want: []string{"groq", "openai"}},
+		{provider: " groq , openai,, ollama ", want: []string{"groq", "openai", "ollama"}},
+		{provider: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{APIProvider: tt.provider}
+		got := cfg.ProviderChain()
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("ProviderChain() for %q = %v, want %v", tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestDenyAndAllowPatterns(t *testing.T) {
+	cfg := &Config{
+		DenyList:  "git push*, *docker system prune*,",
+		AllowList: " rm -rf ./node_modules ",
+	}
+
+	if got := strings.Join(cfg.DenyPatterns(), "|"); got != "git push*|*docker system prune*" {
+		t.Errorf("DenyPatterns() = %q", got)
+	}
+	if got := strings.Join(cfg.AllowPatterns(), "|"); got != "rm -rf ./node_modules" {
+		t.Errorf("AllowPatterns() = %q", got)
+	}
+	if got := (&Config{}).DenyPatterns(); got != nil {
+		t.Errorf("DenyPatterns() of an empty list = %v, want nil", got)
+	}
+}
+
+func TestModel(t *testing.T) {
+	cfg := &Config{
+		APIProvider:    "anthropic, groq",
+		AnthropicModel: "claude-3-5-haiku-latest",
+		GroqModel:      "llama-3.3-70b-versatile",
+	}
+	if got := cfg.Model(); got != "claude-3-5-haiku-latest" {
+		t.Errorf("Model() = %q, want the model of the first provider", got)
+	}
+	if got := (&Config{APIProvider: "unknown"}).Model(); got != "" {
+		t.Errorf("Model() of an unknown provider = %q, want empty", got)
+	}
 }
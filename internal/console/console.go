@@ -0,0 +1,85 @@
+// Package console runs commands attached to the user's terminal while
+// capturing their output, so the output can be used as context for the next
+// command.
+package console
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxCapture is how many bytes of output are kept, only the end of the output
+// is used as context
+const maxCapture = 64 * 1024
+
+// Run runs a command attached to the user's terminal and returns its output.
+// On terminals the command runs in a pseudo-terminal, so programs that need a
+// TTY such as pagers, top or password prompts work, and the output is shown as
+// it arrives. The returned output has terminal escape sequences removed.
+func Run(cmd *exec.Cmd) (string, error) {
+	capture := &tailBuffer{max: maxCapture}
+	err := run(cmd, capture)
+	return Clean(capture.String()), err
+}
+
+// runPlain runs a command with the user's stdin, copying its output to stdout
+// and to the capture
+func runPlain(cmd *exec.Cmd, capture io.Writer) error {
+	out := io.MultiWriter(os.Stdout, capture)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+// Write appends to the buffer, dropping the oldest bytes beyond max
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the bytes kept
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// escapePattern matches terminal escape sequences: CSI sequences such as colors
+// and cursor movement, OSC sequences such as window titles, and two byte
+// escapes
+var escapePattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Clean turns terminal output into plain text. Escape sequences are removed
+// and a carriage return inside a line, as used by progress bars, keeps only
+// the text written after it.
+func Clean(output string) string {
+	output = escapePattern.ReplaceAllString(output, "")
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if j := strings.LastIndexByte(strings.TrimRight(line, "\r"), '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	return strings.Join(lines, "\n")
+}
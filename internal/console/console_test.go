@@ -0,0 +1,56 @@
+package console
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "plain", output: "hello\nworld\n", want: "hello\nworld\n"},
+		{name: "colors", output: "\x1b[1;32mok\x1b[0m done\r\n", want: "ok done\n"},
+		{name: "window title", output: "\x1b]0;user@host: ~\x07prompt", want: "prompt"},
+		{name: "progress bar", output: "downloading 10%\rdownloading 50%\rdownloading 100%\r\ndone\n", want: "downloading 100%\ndone\n"},
+		{name: "cursor movement", output: "a\x1b[2K\x1b[1Gb\n", want: "ab\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Clean(tt.output); got != tt.want {
+				t.Errorf("Clean(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	for _, s := range []string{"abc", "defgh", "ijk"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got, want := b.String(), "defghijk"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+
+	output, err := Run(exec.Command("sh", "-c", "printf 'out\\n'; printf 'err\\n' >&2; exit 3"))
+	if err == nil {
+		t.Error("Run() error = nil, want the exit status")
+	}
+	if !strings.Contains(output, "out\n") || !strings.Contains(output, "err\n") {
+		t.Errorf("Run() output = %q, want stdout and stderr", output)
+	}
+}
@@ -0,0 +1,126 @@
+//go:build !windows
+
+package console
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// pollInterval is how often the stdin copier checks whether the command ended
+const pollInterval = 50 * time.Millisecond
+
+// drainTimeout is how long output is still read after the command ended.
+// Background processes started by the command may keep the pseudo-terminal
+// open, which would otherwise block forever.
+const drainTimeout = 500 * time.Millisecond
+
+// run runs a command in a pseudo-terminal if stdin and stdout are terminals,
+// and with plain pipes otherwise
+func run(cmd *exec.Cmd, capture io.Writer) error {
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return runPlain(cmd, capture)
+	}
+
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	// Keep the size of the pseudo-terminal in sync with the window
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	_ = pty.InheritSize(os.Stdin, ptmx)
+	go func() {
+		for range resize {
+			_ = pty.InheritSize(os.Stdin, ptmx)
+		}
+	}()
+	defer func() {
+		// Stop delivering signals before closing, a signal sent to the closed
+		// channel would panic
+		signal.Stop(resize)
+		close(resize)
+	}()
+
+	// Pass keys through unprocessed, the pseudo-terminal handles Ctrl+C and
+	// echoing for the command
+	if state, err := term.MakeRaw(stdin); err == nil {
+		defer term.Restore(stdin, state)
+	}
+
+	// Forward input until the command ends. Stdin is polled rather than read
+	// directly so no keystroke meant for the next prompt is swallowed.
+	done := make(chan struct{})
+	inputDone := make(chan struct{})
+	go func() {
+		defer close(inputDone)
+		copyInput(ptmx, stdin, done)
+	}()
+
+	// Show the output as it arrives while capturing it
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		_, _ = io.Copy(io.MultiWriter(os.Stdout, capture), ptmx)
+	}()
+
+	err = cmd.Wait()
+	select {
+	case <-outputDone:
+	case <-time.After(drainTimeout):
+		ptmx.Close()
+		<-outputDone
+	}
+	close(done)
+	<-inputDone
+	return err
+}
+
+// copyInput copies what is typed on stdin to the pseudo-terminal until done
+// is closed
+func copyInput(ptmx *os.File, stdin int, done <-chan struct{}) {
+	buf := make([]byte, 1024)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		// Wait for input with select, which unlike poll works with terminals
+		// on macOS
+		var fds unix.FdSet
+		fds.Set(stdin)
+		timeout := unix.NsecToTimeval(pollInterval.Nanoseconds())
+		n, err := unix.Select(stdin+1, &fds, nil, nil, &timeout)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return
+		}
+		if n == 0 || !fds.IsSet(stdin) {
+			continue
+		}
+
+		n, err = unix.Read(stdin, buf)
+		if err != nil || n == 0 {
+			return
+		}
+		if _, err := ptmx.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
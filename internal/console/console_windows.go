@@ -0,0 +1,14 @@
+//go:build windows
+
+package console
+
+import (
+	"io"
+	"os/exec"
+)
+
+// run runs a command with plain pipes, pseudo-terminals are not supported on
+// Windows
+func run(cmd *exec.Cmd, capture io.Writer) error {
+	return runPlain(cmd, capture)
+}
@@ -0,0 +1,77 @@
+// Package explain breaks an existing shell command down part by part, along
+// with what the local safety rules make of it.
+package explain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/llm"
+	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/safety"
+)
+
+// Run explains a command. Pressing Ctrl+C while the explanation is generated
+// cancels the request.
+func Run(ctx context.Context, client *llm.Client, cfg *config.Config, command string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	// Classify the command locally, the LLM's explanation does not replace it
+	analysis := safety.NewAnalyzer(cfg.DenyPatterns(), cfg.AllowPatterns()).Analyze(command)
+
+	explanation, err := client.ExplainCommand(ctx, command)
+	if err != nil {
+		// Check if the request was interrupted with Ctrl+C
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\nExiting...")
+			return nil
+		}
+		return err
+	}
+
+	render(os.Stdout, command, explanation, analysis)
+	return nil
+}
+
+// render prints the explanation of a command followed by its safety analysis
+func render(w io.Writer, command string, explanation *parser.CommandExplanation, analysis *safety.Analysis) {
+	fmt.Fprintf(w, "%s\n\n", command)
+	if explanation.Summary != "" {
+		fmt.Fprintf(w, "%s\n\n", explanation.Summary)
+	}
+
+	// Line the explanations of the parts up in a column
+	if len(explanation.Parts) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, part := range explanation.Parts {
+			fmt.Fprintf(tw, "  %s\t%s\n", singleLine(part.Text), singleLine(part.Explanation))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	if len(analysis.Findings) == 0 {
+		fmt.Fprintf(w, "Risk: %s, no dangerous patterns found\n", analysis.Level)
+	} else {
+		fmt.Fprintf(w, "Risk: %s\n", analysis.Level)
+		for _, finding := range analysis.Findings {
+			fmt.Fprintf(w, "  - %s (%s)\n", finding.Reason, finding.Category)
+		}
+	}
+	if analysis.Denied {
+		fmt.Fprintf(w, "The command matches %q in SHAI_DENY_LIST and would not be run\n", analysis.DeniedBy)
+	}
+}
+
+// singleLine joins the lines of s so it fits in a column
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
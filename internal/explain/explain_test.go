@@ -0,0 +1,54 @@
+package explain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/safety"
+)
+
+func TestRender(t *testing.T) {
+	explanation := &parser.CommandExplanation{
+		Command: "rm -rf /tmp/build",
+		Summary: "Deletes the build directory.",
+		Parts: []parser.CommandPart{
+			{Text: "rm", Explanation: "remove files"},
+			{Text: "-rf", Explanation: "recursively,\nwithout asking"},
+			{Text: "/tmp/build", Explanation: "the directory to delete"},
+		},
+	}
+	analysis := safety.NewAnalyzer([]string{"rm *"}, nil).Analyze(explanation.Command)
+
+	var out bytes.Buffer
+	render(&out, explanation.Command, explanation, analysis)
+
+	want := `rm -rf /tmp/build
+
+Deletes the build directory.
+
+  rm           remove files
+  -rf          recursively, without asking
+  /tmp/build   the directory to delete
+
+Risk: medium
+  - ` + analysis.Findings[0].Reason + ` (destructive)
+The command matches "rm *" in SHAI_DENY_LIST and would not be run
+`
+	if out.String() != want {
+		t.Errorf("render() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderNoFindings(t *testing.T) {
+	explanation := &parser.CommandExplanation{Summary: "Lists files."}
+	analysis := safety.NewAnalyzer(nil, nil).Analyze("ls")
+
+	var out bytes.Buffer
+	render(&out, "ls", explanation, analysis)
+
+	want := "ls\n\nLists files.\n\nRisk: low, no dangerous patterns found\n"
+	if out.String() != want {
+		t.Errorf("render() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,253 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func init() {
+	Register("anthropic", newAnthropicProvider)
+}
+
+// anthropicVersion is the version of the Messages API the provider speaks
+const anthropicVersion = "2023-06-01"
+
+// AnthropicRequest represents a request to the Anthropic Messages API
+type AnthropicRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature"`
+	Stream      bool      `json:"stream,omitempty"`
+
+	Tools      []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice *AnthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// AnthropicTool describes a tool the model may call
+type AnthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// AnthropicToolChoice forces the model to call a tool
+type AnthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// AnthropicContentBlock represents one block of content in a response
+type AnthropicContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	Input json.RawMessage `json:"input"`
+}
+
+// AnthropicResponse represents a response from the Anthropic Messages API
+type AnthropicResponse struct {
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+// AnthropicStreamEvent represents one server-sent event of a streamed response
+type AnthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	url       string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+// newAnthropicProvider creates the provider for the Anthropic Messages API
+func newAnthropicProvider(cfg *config.Config) (Provider, error) {
+	if cfg.AnthropicAPIKey == "" {
+		return nil, &MissingAPIKeyError{Provider: "anthropic", EnvVar: "ANTHROPIC_API_KEY"}
+	}
+
+	// The Messages API requires max_tokens
+	maxTokens := cfg.AnthropicMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+
+	return &anthropicProvider{
+		url:       "https://api.anthropic.com/v1/messages",
+		apiKey:    cfg.AnthropicAPIKey,
+		model:     cfg.AnthropicModel,
+		maxTokens: maxTokens,
+	}, nil
+}
+
+// Name returns the name the provider is registered under
+func (p *anthropicProvider) Name() string {
+	return "anthropic"
+}
+
+// Capabilities reports the optional features the provider supports
+func (p *anthropicProvider) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, StructuredOutput: true}
+}
+
+// NewRequest builds the HTTP request for a completion. System messages are
+// moved to the top level system field as the Messages API expects. A schema
+// is turned into a tool the model is forced to call, whose input is the
+// structured response.
+func (p *anthropicProvider) NewRequest(req *CompletionRequest) (*http.Request, error) {
+	var system []string
+	messages := make([]Message, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	requestBody := AnthropicRequest{
+		Model:       p.model,
+		System:      strings.Join(system, "\n\n"),
+		Messages:    messages,
+		MaxTokens:   p.maxTokens,
+		Temperature: req.Temperature,
+		Stream:      req.Stream,
+	}
+	if req.Schema != nil {
+		requestBody.Tools = []AnthropicTool{{
+			Name:        req.Schema.Name,
+			Description: req.Schema.Description,
+			InputSchema: req.Schema.Definition,
+		}}
+		requestBody.ToolChoice = &AnthropicToolChoice{Type: "tool", Name: req.Schema.Name}
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", p.url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	return httpReq, nil
+}
+
+// ParseResponse decodes a successful HTTP response into a completion
+func (p *anthropicProvider) ParseResponse(resp *http.Response) (*Completion, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response AnthropicResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	// Join the text blocks of the response, the input of a tool call is
+	// the structured response
+	var content strings.Builder
+	found := false
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+			found = true
+		case "tool_use":
+			return &Completion{Choices: []string{string(block.Input)}}, nil
+		}
+	}
+	if !found {
+		return nil, errors.New("no completions returned from API")
+	}
+
+	return &Completion{Choices: []string{content.String()}}, nil
+}
+
+// ParseStream decodes a streamed HTTP response, calling onDelta with the
+// choice index and each fragment of content as it arrives
+func (p *anthropicProvider) ParseStream(resp *http.Response, onDelta func(index int, delta string)) (*Completion, error) {
+	var content strings.Builder
+
+	err := readSSE(resp.Body, func(event, data string) (bool, error) {
+		var streamEvent AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &streamEvent); err != nil {
+			return false, err
+		}
+
+		switch streamEvent.Type {
+		case "content_block_delta":
+			// The input of a tool call streams as fragments of JSON
+			var delta string
+			switch streamEvent.Delta.Type {
+			case "text_delta":
+				delta = streamEvent.Delta.Text
+			case "input_json_delta":
+				delta = streamEvent.Delta.PartialJSON
+			}
+			if delta == "" {
+				return false, nil
+			}
+			content.WriteString(delta)
+			if onDelta != nil {
+				onDelta(0, delta)
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, &APIError{
+				Kind:    classifyAnthropicError(streamEvent.Error.Type),
+				Message: fmt.Sprintf("%s: %s", streamEvent.Error.Type, streamEvent.Error.Message),
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Completion{Choices: []string{content.String()}}, nil
+}
+
+// classifyAnthropicError maps the type of an error event in a stream to an
+// error kind
+func classifyAnthropicError(errorType string) ErrorKind {
+	switch errorType {
+	case "overloaded_error":
+		return ErrorOverloaded
+	case "rate_limit_error":
+		return ErrorRateLimit
+	case "api_error":
+		return ErrorServer
+	case "authentication_error", "permission_error":
+		return ErrorAuth
+	case "invalid_request_error", "not_found_error", "request_too_large":
+		return ErrorBadRequest
+	default:
+		return ErrorUnknown
+	}
+}
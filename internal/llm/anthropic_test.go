@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func TestAnthropicProvider(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"content":[{"type":"text","text":"{\"command\": "},{"type":"text","text":"\"ps aux\"}"}]}`, &got, &gotBody)
+
+	cfg := &config.Config{
+		APIProvider:        "anthropic",
+		AnthropicAPIKey:    "anthropic-key",
+		AnthropicModel:     "test-model",
+		AnthropicMaxTokens: 256,
+		Temperature:        0.2,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.providers[0].(*anthropicProvider).url = srv.URL
+
+	content, err := client.GenerateCompletion(context.Background(), "system prompt", "user prompt")
+	if err != nil {
+		t.Fatalf("GenerateCompletion() error = %v", err)
+	}
+	if content != `{"command": "ps aux"}` {
+		t.Errorf("GenerateCompletion() = %q, want %q", content, `{"command": "ps aux"}`)
+	}
+
+	if h := got.Header.Get("x-api-key"); h != "anthropic-key" {
+		t.Errorf("x-api-key header = %q, want %q", h, "anthropic-key")
+	}
+	if h := got.Header.Get("anthropic-version"); h != anthropicVersion {
+		t.Errorf("anthropic-version header = %q, want %q", h, anthropicVersion)
+	}
+
+	var req AnthropicRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if req.System != "system prompt" {
+		t.Errorf("request system = %q, want %q", req.System, "system prompt")
+	}
+	if len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content != "user prompt" {
+		t.Errorf("request messages = %+v, want a single user message", req.Messages)
+	}
+	if req.Model != "test-model" || req.MaxTokens != 256 {
+		t.Errorf("request body = %+v, want model test-model, max tokens 256", req)
+	}
+}
+
+func TestAnthropicStreaming(t *testing.T) {
+	stream := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"{\\\"command\\\": \"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"\\\"id\\\"}\"}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, stream, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{APIProvider: "anthropic", AnthropicAPIKey: "key", Stream: true})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.providers[0].(*anthropicProvider).url = srv.URL
+
+	var deltas int
+	content, err := client.StreamCompletion(context.Background(), "system", "user", func(string) { deltas++ })
+	if err != nil {
+		t.Fatalf("StreamCompletion() error = %v", err)
+	}
+	if content != `{"command": "id"}` {
+		t.Errorf("StreamCompletion() = %q, want %q", content, `{"command": "id"}`)
+	}
+	if deltas != 2 {
+		t.Errorf("StreamCompletion() called onDelta %d times, want 2", deltas)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	stream := "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, stream, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{APIProvider: "anthropic", AnthropicAPIKey: "key", Stream: true})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.providers[0].(*anthropicProvider).url = srv.URL
+
+	if _, err := client.StreamCompletion(context.Background(), "system", "user", func(string) {}); err == nil {
+		t.Errorf("StreamCompletion() expected an error")
+	}
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func init() {
+	Register("azure", newAzureProvider)
+}
+
+// newAzureProvider creates the provider for an Azure OpenAI deployment. The
+// resource endpoint is taken from OPENAI_API_BASE and the deployment from
+// AZURE_OPENAI_DEPLOYMENT.
+func newAzureProvider(cfg *config.Config) (Provider, error) {
+	if cfg.OpenAIAPIKey == "" {
+		return nil, &MissingAPIKeyError{Provider: "azure", EnvVar: "OPENAI_API_KEY"}
+	}
+	if cfg.OpenAIAPIBase == "" {
+		return nil, errors.New("azure provider requires OPENAI_API_BASE to be set to the resource endpoint")
+	}
+	if cfg.AzureDeployment == "" {
+		return nil, errors.New("azure provider requires AZURE_OPENAI_DEPLOYMENT to be set")
+	}
+
+	apiURL := strings.TrimRight(cfg.OpenAIAPIBase, "/") +
+		"/openai/deployments/" + url.PathEscape(cfg.AzureDeployment) +
+		"/chat/completions?api-version=" + url.QueryEscape(cfg.OpenAIAPIVersion)
+
+	return &chatCompletionsProvider{
+		name:  "azure",
+		url:   apiURL,
+		model: cfg.AzureDeployment,
+		headers: map[string]string{
+			"api-key": cfg.OpenAIAPIKey,
+		},
+		capabilities: Capabilities{
+			Streaming: true,
+			// JSON mode arrived with API version 2023-12-01-preview and
+			// json_schema with 2024-08-01-preview
+			StructuredOutput: cfg.OpenAIAPIVersion >= "2023-12-01",
+		},
+		jsonMode: cfg.OpenAIAPIVersion < "2024-08-01",
+	}, nil
+}
@@ -1,23 +1,44 @@
 package llm
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"runtime"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/platform"
+	"github.com/jwswj/shell-ai/internal/shell"
+	"github.com/jwswj/shell-ai/internal/tools"
 )
 
+// probePlatform and loadTools describe the system the commands run on. They
+// run commands and write to the user's cache directory, so tests replace them.
+var (
+	probePlatform = platform.Probe
+	loadTools     = tools.Load
+)
+
+// warnings is where NewClient reports the providers it leaves out
+var warnings io.Writer = os.Stderr
+
 // Client represents an LLM client
 type Client struct {
-	config *config.Config
-	client *http.Client
+	config    *config.Config
+	client    *http.Client
+	providers []Provider
+	retry     retryPolicy
+
+	// responseTimeout limits how long a single attempt may take, including
+	// reading a streamed response, zero means no limit
+	responseTimeout time.Duration
+
+	// transcript holds the steps of a context mode session
+	transcript *parser.ContextManager
 }
 
 // Message represents a chat message
@@ -26,177 +47,322 @@ type Message struct {
 	Content string `json:"content"`
 }
 
-// ChatRequest represents a chat completion request
-type ChatRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-}
+// NewClient creates a new LLM client for the providers listed in
+// SHAI_API_PROVIDER. A name that is not a provider is an error, most likely a
+// typo. Providers that cannot be created, for example because their API key is
+// missing, are left out of the fallback chain with a warning; an error is
+// returned only if none of them can be created.
+func NewClient(cfg *config.Config) (*Client, error) {
+	var providers []Provider
+	var errs []error
+	for _, name := range cfg.ProviderChain() {
+		provider, err := NewProvider(name, cfg)
+		if errors.Is(err, ErrUnsupportedProvider) {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	if len(providers) == 0 {
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedProvider, cfg.APIProvider)
+		}
+		return nil, errs[0]
+	}
 
-// ChatResponse represents a chat completion response
-type ChatResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-}
+	// The chain works without them, but the user asked for them
+	for _, err := range errs {
+		fmt.Fprintf(warnings, "Warning: skipping provider: %v\n", err)
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-// NewClient creates a new LLM client
-func NewClient(cfg *config.Config) (*Client, error) {
 	return &Client{
-		config: cfg,
+		config:          cfg,
+		providers:       providers,
+		retry:           newRetryPolicy(cfg.MaxAttempts),
+		responseTimeout: time.Duration(cfg.ResponseTimeout) * time.Second,
+		transcript:      newTranscript(cfg),
 		client: &http.Client{
-			Timeout: 60 * time.Second,
+			Transport: transport,
 		},
 	}, nil
 }
 
-// GenerateCompletion generates a completion from the LLM
-func (c *Client) GenerateCompletion(systemPrompt, userPrompt string) (string, error) {
-	var apiURL string
-	var apiKey string
-	var model string
-	var headers map[string]string
+// GenerateCompletion generates a completion from the LLM. Requests in flight
+// are aborted when the context is done.
+func (c *Client) GenerateCompletion(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return c.StreamCompletion(ctx, systemPrompt, userPrompt, nil)
+}
 
-	// Configure API based on provider
-	switch c.config.APIProvider {
-	case "openai":
-		apiURL = "https://api.openai.com/v1/chat/completions"
-		if c.config.OpenAIAPIBase != "" {
-			apiURL = c.config.OpenAIAPIBase + "/v1/chat/completions"
-		}
-		apiKey = c.config.OpenAIAPIKey
-		model = c.config.OpenAIModel
-		headers = map[string]string{
-			"Content-Type":  "application/json",
-			"Authorization": "Bearer " + apiKey,
-		}
-		if c.config.OpenAIOrganization != "" {
-			headers["OpenAI-Organization"] = c.config.OpenAIOrganization
-		}
-	case "groq":
-		apiURL = "https://api.groq.com/openai/v1/chat/completions"
-		apiKey = c.config.GroqAPIKey
-		model = c.config.GroqModel
-		headers = map[string]string{
-			"Content-Type":  "application/json",
-			"Authorization": "Bearer " + apiKey,
-		}
-	default:
-		return "", fmt.Errorf("unsupported API provider: %s", c.config.APIProvider)
+// StreamCompletion generates a completion from the LLM, calling onDelta with
+// each fragment of the response as it arrives. The completion is streamed only
+// if onDelta is set, streaming is enabled and the provider supports it.
+func (c *Client) StreamCompletion(ctx context.Context, systemPrompt, userPrompt string, onDelta func(delta string)) (string, error) {
+	completion, err := c.streamSingle(ctx, systemPrompt, userPrompt, nil, onDelta)
+	if err != nil {
+		return "", err
+	}
+	return completion.Content(), nil
+}
+
+// streamSingle generates a completion with a single choice, constrained to the
+// schema if it is set
+func (c *Client) streamSingle(ctx context.Context, systemPrompt, userPrompt string, schema *Schema, onDelta func(delta string)) (*Completion, error) {
+	return c.complete(ctx, func() *CompletionRequest {
+		return c.newRequest(systemPrompt, userPrompt, schema)
+	}, singleChoice(onDelta))
+}
+
+// singleChoice adapts a callback for the fragments of a single choice to the
+// per choice callback of complete
+func singleChoice(onDelta func(delta string)) func(int, string) {
+	if onDelta == nil {
+		return nil
 	}
+	return func(_ int, delta string) { onDelta(delta) }
+}
 
-	// Create request body
-	messages := []Message{
+// newRequest creates a completion request. The schema is left out if
+// structured output is disabled.
+func (c *Client) newRequest(systemPrompt, userPrompt string, schema *Schema) *CompletionRequest {
+	return c.newChatRequest([]Message{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
+	}, schema)
+}
+
+// newChatRequest creates a completion request continuing a conversation
+func (c *Client) newChatRequest(messages []Message, schema *Schema) *CompletionRequest {
+	if !c.config.StructuredOutput {
+		schema = nil
 	}
 
-	requestBody := ChatRequest{
-		Model:       model,
+	return &CompletionRequest{
 		Messages:    messages,
 		Temperature: c.config.Temperature,
+		MaxTokens:   c.config.OpenAIMaxTokens,
+		Schema:      schema,
 	}
+}
 
-	if c.config.OpenAIMaxTokens > 0 {
-		requestBody.MaxTokens = c.config.OpenAIMaxTokens
-	}
+// complete sends the request made by build to each provider of the fallback
+// chain in turn, moving on to the next provider when one fails with an error
+// that is worth retrying. The completion records which provider produced it.
+func (c *Client) complete(ctx context.Context, build func() *CompletionRequest, onDelta func(index int, delta string)) (*Completion, error) {
+	var lastErr error
+	for i, provider := range c.providers {
+		completion, err := c.completeWith(ctx, provider, build(), onDelta)
+		if err == nil {
+			completion.Provider = provider.Name()
+			return completion, nil
+		}
+		lastErr = err
 
-	// Marshal request body
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", err
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !apiErr.Retryable() {
+			return nil, err
+		}
+		if i+1 < len(c.providers) {
+			c.config.DebugPrint("Provider %s failed, falling back to %s: %v\n", provider.Name(), c.providers[i+1].Name(), err)
+		}
 	}
+	return nil, lastErr
+}
 
-	// Create request
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", err
+// completeWith sends a completion request to a single provider, retrying
+// according to the retry policy. Each attempt is limited to the response
+// timeout, an attempt that times out is retried like a network error.
+func (c *Client) completeWith(ctx context.Context, provider Provider, req *CompletionRequest, onDelta func(index int, delta string)) (*Completion, error) {
+	// Once part of a stream has been passed on it cannot be taken back, so
+	// only requests that failed before streaming began are retried
+	streamed := false
+	if onDelta != nil && c.config.Stream && provider.Capabilities().Streaming {
+		req.Stream = true
+		req.OnDelta = func(index int, delta string) {
+			streamed = true
+			onDelta(index, delta)
+		}
 	}
 
-	// Add headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
+	return c.retry.do(ctx, func() (*Completion, error) {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.responseTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, c.responseTimeout)
+		}
+		defer cancel()
 
-	// Send request
-	resp, err := c.client.Do(req)
+		completion, err := send(attemptCtx, c.client, provider, req)
+		switch {
+		case err == nil:
+			return completion, nil
+		case ctx.Err() != nil:
+			// Cancelled by the caller, the error is not retryable
+			return nil, ctx.Err()
+		case streamed:
+			return nil, &APIError{Kind: ErrorUnknown, Message: fmt.Sprintf("stream interrupted: %v", err)}
+		case errors.Is(attemptCtx.Err(), context.DeadlineExceeded):
+			return nil, &APIError{Kind: ErrorNetwork, Err: fmt.Errorf("no response from %s within %s", provider.Name(), c.responseTimeout)}
+		}
+		return nil, err
+	}, func(wait time.Duration, err error) {
+		c.config.DebugPrint("Retrying %s request in %s: %v\n", provider.Name(), wait.Round(time.Millisecond), err)
+	})
+}
+
+// GenerateShellCommand generates a shell command from a user prompt
+func (c *Client) GenerateShellCommand(ctx context.Context, userPrompt string) (*parser.CommandResponse, error) {
+	completion, err := c.StreamShellCommand(ctx, userPrompt, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	commands, err := completion.Commands()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return &commands[0], nil
+}
+
+// StreamShellCommand generates a shell command from a user prompt, calling
+// onDelta with each fragment of the response as it arrives. The remembered
+// steps of a context mode session are sent along.
+func (c *Client) StreamShellCommand(ctx context.Context, userPrompt string, onDelta func(delta string)) (*Completion, error) {
+	return c.complete(ctx, func() *CompletionRequest {
+		messages := c.conversation(shellCommandSystemPrompt(userPrompt, 1), userPrompt, 1)
+		return c.newChatRequest(messages, commandSchema(1))
+	}, singleChoice(onDelta))
+}
 
-	// Check for errors
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+// StreamShellCommands generates n alternative shell commands from a user
+// prompt with a single request for a list of n distinct commands. Asking for n
+// choices instead would sample the same prompt n times, which at the low
+// default temperature returns the same command n times. The raw response is
+// the choice of the completion, onDelta is called with the choice index and
+// each fragment of the response as it arrives. The remembered steps of a
+// context mode session are sent along.
+func (c *Client) StreamShellCommands(ctx context.Context, userPrompt string, n int, onDelta func(index int, delta string)) (*Completion, error) {
+	return c.complete(ctx, func() *CompletionRequest {
+		messages := c.conversation(shellCommandSystemPrompt(userPrompt, n), userPrompt, n)
+		return c.newChatRequest(messages, commandSchema(n))
+	}, onDelta)
+}
+
+// commandSchema returns the schema of a response holding n commands, matching
+// the formats asked for by shellCommandSystemPrompt
+func commandSchema(n int) *Schema {
+	// Strict structured output requires every property to be required
+	command := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command":     map[string]any{"type": "string"},
+			"explanation": map[string]any{"type": "string"},
+			"risk": map[string]any{
+				"type": "string",
+				"enum": []string{parser.RiskLow, parser.RiskMedium, parser.RiskHigh},
+			},
+			"requires": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+			},
+			"modifies_files": map[string]any{"type": "boolean"},
+		},
+		"required":             []string{"command", "explanation", "risk", "requires", "modifies_files"},
+		"additionalProperties": false,
 	}
 
-	// Parse response
-	var chatResponse ChatResponse
-	err = json.Unmarshal(body, &chatResponse)
-	if err != nil {
-		return "", err
+	if n > 1 {
+		return &Schema{
+			Name:        "suggest_commands",
+			Description: "Suggest alternative shell commands that satisfy the user request",
+			Definition: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"commands": map[string]any{
+						"type":  "array",
+						"items": command,
+					},
+				},
+				"required":             []string{"commands"},
+				"additionalProperties": false,
+			},
+		}
 	}
 
-	// Check if we have choices
-	if len(chatResponse.Choices) == 0 {
-		return "", errors.New("no completions returned from API")
+	return &Schema{
+		Name:        "suggest_command",
+		Description: "Suggest a shell command that satisfies the user request",
+		Definition:  command,
+	}
+}
+
+// Commands returns the commands held by the choices of a completion of
+// StreamShellCommand or StreamShellCommands. Structured completions are decoded
+// directly, free form responses and structured ones that fail to decode fall
+// back to extracting the JSON from the text.
+func (c *Completion) Commands() ([]parser.CommandResponse, error) {
+	var commands []parser.CommandResponse
+	var firstErr error
+	for _, choice := range c.Choices {
+		var responses []parser.CommandResponse
+		var err error
+		if c.Structured {
+			responses, err = parser.DecodeCommandResponses(choice)
+		}
+		if !c.Structured || err != nil {
+			responses, err = parser.ParseCommandResponses(choice)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		commands = append(commands, responses...)
 	}
 
-	return chatResponse.Choices[0].Message.Content, nil
+	if len(commands) == 0 {
+		if firstErr == nil {
+			firstErr = errors.New("no commands in response")
+		}
+		return nil, firstErr
+	}
+	return commands, nil
 }
 
-// GenerateShellCommand generates a shell command from a user prompt
-func (c *Client) GenerateShellCommand(userPrompt, context string) (string, error) {
+// commandFormat is the JSON object the system prompt asks for each command
+const commandFormat = `{"command": "your_shell_command_here", "explanation": "what the command does", "risk": "low", "requires": ["binary"], "modifies_files": false}`
+
+// commandFields describes the fields of commandFormat besides the command
+const commandFields = `The "explanation" key holds a single short line describing what the command does. The "risk" key is "low" for read only commands, "medium" for commands that change files or settings, and "high" for commands that delete data, need root or are hard to undo. The "requires" key lists the binaries the command runs. The "modifies_files" key is true if the command creates, changes or deletes files.`
+
+// shellCommandSystemPrompt creates the system prompt asking for n commands
+// that satisfy the user prompt
+func shellCommandSystemPrompt(userPrompt string, n int) string {
 	// Create system prompt
-	systemPrompt := "You are an expert at using shell commands. I need you to provide a response in the format `{\"command\": \"your_shell_command_here\"}`. Only provide a single executable line of shell code as the value for the \"command\" key. Never output any text outside the JSON structure. The command will be directly executed in a shell."
+	systemPrompt := "You are an expert at using shell commands. I need you to provide a response in the format `" + commandFormat + "`. Only provide a single executable line of shell code as the value for the \"command\" key. " + commandFields + " Never output any text outside the JSON structure. The command will be directly executed in a shell."
+	if n > 1 {
+		systemPrompt = fmt.Sprintf("You are an expert at using shell commands. I need you to provide a response in the format `{\"commands\": [%s, ...]}` with exactly %d distinct alternatives. Each \"command\" value in the \"commands\" list must be a single executable line of shell code. %s Never output any text outside the JSON structure. The commands will be directly executed in a shell.", commandFormat, n, commandFields)
+	}
 
 	// Add platform information
 	platformInfo := getPlatformInfo()
 	systemPrompt += " " + platformInfo
 
-	// Add context if available
-	if context != "" {
-		systemPrompt += fmt.Sprintf(" Between [], these are the last %d tokens from the previous command's output, you can use them as context: [%s]",
-			len(context), context)
-	}
+	// Add the tools that are installed
+	systemPrompt += " " + loadTools().Summary(userPrompt)
 
-	// Generate completion
-	userPromptWithPrefix := fmt.Sprintf("Generate a shell command that satisfies this user request: %s", userPrompt)
-	return c.GenerateCompletion(systemPrompt, userPromptWithPrefix)
+	return systemPrompt
 }
 
-// getPlatformInfo returns information about the current platform
+// getPlatformInfo returns a description of the platform and shell the command
+// will be executed with
 func getPlatformInfo() string {
-	// This is a simplified version - in a real implementation, you would use
-	// more detailed platform detection like in the Python version
-	return fmt.Sprintf("The system the shell command will be executed on is %s.", getOSName())
-}
-
-// getOSName returns the name of the operating system
-func getOSName() string {
-	// Simple OS detection - could be expanded with more detailed information
-	switch {
-	case strings.Contains(strings.ToLower(getOSRelease()), "darwin"):
-		return "macOS"
-	case strings.Contains(strings.ToLower(getOSRelease()), "linux"):
-		return "Linux"
-	case strings.Contains(strings.ToLower(getOSRelease()), "windows"):
-		return "Windows"
-	default:
-		return "Unknown"
-	}
-}
-
-// getOSRelease returns the OS release information
-func getOSRelease() string {
-	return runtime.GOOS
+	return fmt.Sprintf("%s The command will be run with %s, use its syntax.", probePlatform().Summary(), shell.Detect())
 }
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func TestProviderFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		firstStatus  int
+		wantProvider string
+		wantErr      bool
+	}{
+		{name: "primary succeeds", firstStatus: http.StatusOK, wantProvider: "openai"},
+		{name: "overloaded primary", firstStatus: http.StatusServiceUnavailable, wantProvider: "local"},
+		{name: "rate limited primary", firstStatus: http.StatusTooManyRequests, wantProvider: "local"},
+		{name: "bad request is not failed over", firstStatus: http.StatusBadRequest, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.firstStatus)
+				io.WriteString(w, `{"choices":[{"message":{"content":"primary"}}]}`)
+			}))
+			defer primary.Close()
+
+			secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, `{"choices":[{"message":{"content":"secondary"}}]}`)
+			}))
+			defer secondary.Close()
+
+			client, err := NewClient(&config.Config{
+				APIProvider:   "openai, local",
+				OpenAIAPIKey:  "test-key",
+				OpenAIAPIBase: primary.URL,
+				LocalAPIBase:  secondary.URL,
+			})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			completion, err := client.StreamShellCommand(context.Background(), "list files", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StreamShellCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && completion.Provider != tt.wantProvider {
+				t.Errorf("StreamShellCommand() provider = %q, want %q", completion.Provider, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestNewClientSkipsUnavailableProviders(t *testing.T) {
+	var warned strings.Builder
+	warnings = &warned
+	t.Cleanup(func() { warnings = os.Stderr })
+
+	client, err := NewClient(&config.Config{APIProvider: "groq,ollama"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if len(client.providers) != 1 || client.providers[0].Name() != "ollama" {
+		t.Errorf("NewClient() providers = %v, want only ollama", client.providers)
+	}
+	if !strings.Contains(warned.String(), "GROQ_API_KEY") {
+		t.Errorf("NewClient() warned %q, want the skipped groq provider", warned.String())
+	}
+
+	// The first error is reported when no provider can be created
+	_, err = NewClient(&config.Config{APIProvider: "groq,openai"})
+	var keyErr *MissingAPIKeyError
+	if !errors.As(err, &keyErr) || keyErr.EnvVar != "GROQ_API_KEY" {
+		t.Errorf("NewClient() error = %v, want a missing GROQ_API_KEY error", err)
+	}
+}
+
+func TestNewClientUnsupportedProvider(t *testing.T) {
+	_, err := NewClient(&config.Config{APIProvider: "ollama,olama"})
+	if !errors.Is(err, ErrUnsupportedProvider) || !strings.Contains(err.Error(), "olama") {
+		t.Errorf("NewClient() error = %v, want the unsupported olama provider", err)
+	}
+}
+
+func TestCancelInFlightRequest(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Hang until the client goes away, which the server only notices
+		// once the request body has been read
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(&config.Config{
+		APIProvider:   "openai",
+		OpenAIAPIKey:  "test-key",
+		OpenAIAPIBase: srv.URL,
+		MaxAttempts:   3,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.GenerateCompletion(ctx, "system", "user")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GenerateCompletion() error = %v, want the context error", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("server received %d requests, want 1, cancelled requests are not retried", n)
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if requests.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"uptime"}}]}`)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(&config.Config{
+		APIProvider:   "openai",
+		OpenAIAPIKey:  "test-key",
+		OpenAIAPIBase: srv.URL,
+		MaxAttempts:   2,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.responseTimeout = 50 * time.Millisecond
+	client.retry.sleep = func(context.Context, time.Duration) error { return nil }
+
+	// The attempt that times out is retried
+	content, err := client.GenerateCompletion(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("GenerateCompletion() error = %v", err)
+	}
+	if content != "uptime" {
+		t.Errorf("GenerateCompletion() = %q, want %q", content, "uptime")
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
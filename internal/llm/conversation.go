@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/tokens"
+)
+
+// sessionInstructions tells the model how to use the earlier messages of a
+// context mode session
+const sessionInstructions = " The earlier messages are the previous steps of this session: what the user asked for, the command that was run and its result. Use them to resolve references such as \"it\", \"those files\" or \"the largest one\"."
+
+// newTranscript creates the transcript of a context mode session, measured
+// with the tokenizer of the configured model
+func newTranscript(cfg *config.Config) *parser.ContextManager {
+	model := cfg.Model()
+	budget := cfg.ContextTokens
+	if budget <= 0 {
+		budget = tokens.DefaultBudget(model)
+	}
+
+	transcript := parser.NewContextManager()
+	transcript.SetBudget(tokens.ForModel(model), budget)
+	return transcript
+}
+
+// Remember adds a step of a context mode session to the transcript. The steps
+// that fit the context budget are sent as chat history with the following
+// requests for shell commands.
+func (c *Client) Remember(turn parser.Turn) {
+	c.transcript.AddTurn(turn)
+}
+
+// commandRequest returns the user message asking for n commands, matching the
+// format asked for by shellCommandSystemPrompt
+func commandRequest(prompt string, n int) string {
+	if n > 1 {
+		return fmt.Sprintf("Generate %d distinct shell commands that each satisfy this user request: %s", n, prompt)
+	}
+	return fmt.Sprintf("Generate a shell command that satisfies this user request: %s", prompt)
+}
+
+// conversation returns the messages of a request for n shell commands: the
+// system prompt, the remembered steps as alternating user and assistant
+// messages, and the request. The steps are sent in the format of the request,
+// so the model is not led to answer in the format of a different request. The
+// result of each step is sent with the user message that follows it.
+func (c *Client) conversation(systemPrompt, prompt string, n int) []Message {
+	turns, omitted := c.transcript.Window()
+	if len(turns) > 0 || omitted > 0 {
+		systemPrompt += sessionInstructions
+	}
+
+	messages := make([]Message, 0, 2*len(turns)+2)
+	messages = append(messages, Message{Role: "system", Content: systemPrompt})
+
+	var preamble string
+	if omitted > 0 {
+		preamble = fmt.Sprintf("(%d earlier steps of this session were left out)\n\n", omitted)
+	}
+	for _, turn := range turns {
+		messages = append(messages,
+			Message{Role: "user", Content: preamble + commandRequest(turn.Prompt, n)},
+			Message{Role: "assistant", Content: commandAnswer(turn.Command, n)},
+		)
+		preamble = turnResult(turn) + "\n\n"
+	}
+	return append(messages, Message{Role: "user", Content: preamble + commandRequest(prompt, n)})
+}
+
+// commandAnswer returns the command that was run in the format asked for n
+// commands. Only the command that was run is known, so a list holds just it.
+func commandAnswer(command string, n int) string {
+	var response any = parser.CommandResponse{Command: command}
+	if n > 1 {
+		response = parser.CommandsResponse{Commands: []parser.CommandResponse{{Command: command}}}
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return command
+	}
+	return string(data)
+}
+
+// turnResult describes what running the command of a step did
+func turnResult(turn parser.Turn) string {
+	switch {
+	case turn.ExitCode < 0:
+		return "The command could not be run."
+	case turn.Output == "":
+		return fmt.Sprintf("The command exited with status %d and printed nothing.", turn.ExitCode)
+	default:
+		return fmt.Sprintf("The command exited with status %d and printed:\n%s", turn.ExitCode, turn.Output)
+	}
+}
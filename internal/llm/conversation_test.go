@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/parser"
+)
+
+func TestConversationHistory(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":"{\"command\": \"rm big.iso\"}"}}]}`, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{
+		APIProvider:   "openai",
+		OpenAIAPIKey:  "test-key",
+		OpenAIModel:   "gpt-4o-mini",
+		OpenAIAPIBase: srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	client.Remember(parser.Turn{Prompt: "find the largest files", Command: "du -ah . | sort -rh | head -3", Output: "4.0G\t./big.iso\n1.2G\t./small.iso\n"})
+	client.Remember(parser.Turn{Prompt: "check the disk", Command: "df -h /missing", ExitCode: 1})
+
+	if _, err := client.GenerateShellCommand(context.Background(), "now delete the largest one"); err != nil {
+		t.Fatalf("GenerateShellCommand() error = %v", err)
+	}
+
+	var req ChatRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+
+	roles := make([]string, len(req.Messages))
+	for i, message := range req.Messages {
+		roles[i] = message.Role
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user,assistant,user" {
+		t.Fatalf("request roles = %s, want the steps as alternating messages", got)
+	}
+
+	if !strings.Contains(req.Messages[0].Content, sessionInstructions) {
+		t.Errorf("system prompt does not explain the history:\n%s", req.Messages[0].Content)
+	}
+	if want := commandRequest("find the largest files", 1); req.Messages[1].Content != want {
+		t.Errorf("first user message = %q, want %q", req.Messages[1].Content, want)
+	}
+	if want := `{"command":"du -ah . | sort -rh | head -3"}`; req.Messages[2].Content != want {
+		t.Errorf("first assistant message = %q, want %q", req.Messages[2].Content, want)
+	}
+	if want := "The command exited with status 0 and printed:\n4.0G\t./big.iso\n1.2G\t./small.iso\n\n" + commandRequest("check the disk", 1); req.Messages[3].Content != want {
+		t.Errorf("second user message = %q, want %q", req.Messages[3].Content, want)
+	}
+	if want := "The command exited with status 1 and printed nothing.\n\n" + commandRequest("now delete the largest one", 1); req.Messages[5].Content != want {
+		t.Errorf("last user message = %q, want %q", req.Messages[5].Content, want)
+	}
+}
+
+func TestConversationHistoryForBatch(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":"{\"commands\": [{\"command\": \"rm big.iso\"}, {\"command\": \"rm -f big.iso\"}, {\"command\": \"unlink big.iso\"}]}"}}]}`, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{
+		APIProvider:   "openai",
+		OpenAIAPIKey:  "test-key",
+		OpenAIModel:   "gpt-4o-mini",
+		OpenAIAPIBase: srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	client.Remember(parser.Turn{Prompt: "find the largest files", Command: "du -ah . | sort -rh | head -3", Output: "4.0G\t./big.iso\n"})
+
+	if _, err := client.StreamShellCommands(context.Background(), "now delete the largest one", 3, nil); err != nil {
+		t.Fatalf("StreamShellCommands() error = %v", err)
+	}
+
+	var req ChatRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("request messages = %+v, want the system prompt, a step and the request", req.Messages)
+	}
+
+	// The step is replayed in the format of the batch request
+	if want := commandRequest("find the largest files", 3); req.Messages[1].Content != want {
+		t.Errorf("first user message = %q, want %q", req.Messages[1].Content, want)
+	}
+	if want := `{"commands":[{"command":"du -ah . | sort -rh | head -3"}]}`; req.Messages[2].Content != want {
+		t.Errorf("assistant message = %q, want %q", req.Messages[2].Content, want)
+	}
+	if want := "The command exited with status 0 and printed:\n4.0G\t./big.iso\n\n" + commandRequest("now delete the largest one", 3); req.Messages[3].Content != want {
+		t.Errorf("last user message = %q, want %q", req.Messages[3].Content, want)
+	}
+}
+
+func TestConversationWithoutHistory(t *testing.T) {
+	client := &Client{config: &config.Config{}, transcript: newTranscript(&config.Config{})}
+
+	messages := client.conversation("system prompt", "list files", 1)
+	if len(messages) != 2 || messages[0].Content != "system prompt" || messages[1].Content != commandRequest("list files", 1) {
+		t.Errorf("conversation() = %+v, want just the system prompt and request", messages)
+	}
+}
+
+func TestConversationLeavesOutOldSteps(t *testing.T) {
+	client := &Client{config: &config.Config{}, transcript: newTranscript(&config.Config{ContextTokens: 100})}
+	for i := 0; i < 10; i++ {
+		client.Remember(parser.Turn{Prompt: "count the lines", Command: "wc -l *.go", ExitCode: -1})
+	}
+
+	messages := client.conversation("system prompt", "count the lines", 1)
+	if len(messages) >= 22 || !strings.HasPrefix(messages[1].Content, "(") || !strings.Contains(messages[1].Content, "earlier steps of this session were left out") {
+		t.Errorf("conversation() = %+v, want the oldest steps left out and noted", messages)
+	}
+	if want := "The command could not be run.\n\n" + commandRequest("count the lines", 1); messages[len(messages)-1].Content != want {
+		t.Errorf("last message = %q, want %q", messages[len(messages)-1].Content, want)
+	}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jwswj/shell-ai/internal/parser"
+)
+
+// explanationFormat is the JSON object the explanation prompt asks for
+const explanationFormat = `{"command": "the_command_being_explained", "summary": "what the command does as a whole", "parts": [{"text": "token_or_flag", "explanation": "what this part does"}]}`
+
+// ExplainCommand asks the LLM to break an existing command down into its
+// tokens and flags
+func (c *Client) ExplainCommand(ctx context.Context, command string) (*parser.CommandExplanation, error) {
+	systemPrompt := "You are an expert at using shell commands. Explain the shell command given by the user. I need you to provide a response in the format `" + explanationFormat + "`. The \"command\" key holds the command unchanged. The \"summary\" key holds one or two sentences describing what the command does as a whole. The \"parts\" key lists every program, subcommand, flag, argument, pipe and redirection of the command in order, with a short explanation of each; a flag and its value are one part. Never output any text outside the JSON structure. " + getPlatformInfo()
+	userPrompt := fmt.Sprintf("Explain this shell command: %s", command)
+
+	completion, err := c.streamSingle(ctx, systemPrompt, userPrompt, explanationSchema(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response := completion.Content()
+	if completion.Structured {
+		if explanation, err := parser.DecodeCommandExplanation(response); err == nil {
+			return explanation, nil
+		}
+	}
+	return parser.ParseCommandExplanation(response)
+}
+
+// explanationSchema returns the schema of a command explanation, matching the
+// format asked for by ExplainCommand
+func explanationSchema() *Schema {
+	return &Schema{
+		Name:        "explain_command",
+		Description: "Explain a shell command part by part",
+		Definition: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string"},
+				"summary": map[string]any{"type": "string"},
+				"parts": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"text":        map[string]any{"type": "string"},
+							"explanation": map[string]any{"type": "string"},
+						},
+						"required":             []string{"text", "explanation"},
+						"additionalProperties": false,
+					},
+				},
+			},
+			"required":             []string{"command", "summary", "parts"},
+			"additionalProperties": false,
+		},
+	}
+}
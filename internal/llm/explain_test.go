@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func TestExplainCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		structured bool
+	}{
+		{
+			name:       "structured",
+			content:    `{"command": "ls -la", "summary": "Lists all files in long format.", "parts": [{"text": "ls", "explanation": "list directory contents"}, {"text": "-la", "explanation": "long format, including hidden files"}]}`,
+			structured: true,
+		},
+		{
+			name:    "free form",
+			content: "Sure! Here is the breakdown:\n```json\n{\"command\": \"ls -la\", \"summary\": \"Lists all files in long format.\", \"parts\": [{\"text\": \"ls\", \"explanation\": \"list directory contents\"}, {\"text\": \"-la\", \"explanation\": \"long format, including hidden files\"},]}\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, _ := json.Marshal(tt.content)
+			var got http.Request
+			var gotBody []byte
+			srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":`+string(content)+`}}]}`, &got, &gotBody)
+
+			client, err := NewClient(&config.Config{
+				APIProvider:      "openai",
+				OpenAIAPIKey:     "test-key",
+				OpenAIModel:      "gpt-4o-mini",
+				OpenAIAPIBase:    srv.URL,
+				StructuredOutput: tt.structured,
+			})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			explanation, err := client.ExplainCommand(context.Background(), "ls -la")
+			if err != nil {
+				t.Fatalf("ExplainCommand() error = %v", err)
+			}
+			if explanation.Summary != "Lists all files in long format." {
+				t.Errorf("Summary = %q", explanation.Summary)
+			}
+			if len(explanation.Parts) != 2 || explanation.Parts[1].Text != "-la" {
+				t.Errorf("Parts = %+v, want ls and -la", explanation.Parts)
+			}
+
+			var req ChatRequest
+			if err := json.Unmarshal(gotBody, &req); err != nil {
+				t.Fatalf("Failed to decode request body: %v", err)
+			}
+			if tt.structured && (req.ResponseFormat == nil || req.ResponseFormat.JSONSchema == nil || req.ResponseFormat.JSONSchema.Name != "explain_command") {
+				t.Errorf("request response_format = %+v, want the explain_command schema", req.ResponseFormat)
+			}
+		})
+	}
+}
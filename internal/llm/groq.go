@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func init() {
+	Register("groq", newGroqProvider)
+}
+
+// newGroqProvider creates the provider for the Groq API, which is compatible
+// with the OpenAI chat completions API
+func newGroqProvider(cfg *config.Config) (Provider, error) {
+	if cfg.GroqAPIKey == "" {
+		return nil, &MissingAPIKeyError{Provider: "groq", EnvVar: "GROQ_API_KEY"}
+	}
+
+	return &chatCompletionsProvider{
+		name:  "groq",
+		url:   "https://api.groq.com/openai/v1/chat/completions",
+		model: cfg.GroqModel,
+		headers: map[string]string{
+			"Authorization": "Bearer " + cfg.GroqAPIKey,
+		},
+		capabilities: Capabilities{
+			// Groq rejects requests with n > 1, and its JSON mode cannot be
+			// combined with streaming
+			Streaming: true,
+		},
+	}, nil
+}
@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func init() {
+	Register("local", newLocalProvider)
+}
+
+// newLocalProvider creates the provider for a local server implementing the
+// OpenAI chat completions API, such as the llama.cpp server. The API key is
+// optional.
+func newLocalProvider(cfg *config.Config) (Provider, error) {
+	headers := map[string]string{}
+	if cfg.LocalAPIKey != "" {
+		headers["Authorization"] = "Bearer " + cfg.LocalAPIKey
+	}
+
+	return &chatCompletionsProvider{
+		name:    "local",
+		url:     hostURL(cfg.LocalAPIBase) + "/v1/chat/completions",
+		model:   cfg.LocalModel,
+		headers: headers,
+		capabilities: Capabilities{
+			// llama.cpp, vLLM and LM Studio all accept json_schema
+			Streaming:        true,
+			StructuredOutput: true,
+		},
+	}, nil
+}
@@ -0,0 +1,21 @@
+package llm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/platform"
+	"github.com/jwswj/shell-ai/internal/tools"
+)
+
+func TestMain(m *testing.M) {
+	// Describe a fixed system rather than probing the real one, which runs
+	// commands and writes to the user's cache directory
+	probePlatform = func() *platform.Info {
+		return &platform.Info{OS: "Linux", Arch: "amd64", Userland: platform.UserlandGNU}
+	}
+	loadTools = func() *tools.Index {
+		return tools.Scan("")
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func init() {
+	Register("ollama", newOllamaProvider)
+}
+
+// OllamaChatRequest represents a request to the Ollama chat API
+type OllamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  OllamaOptions `json:"options"`
+
+	// Format is the JSON schema the response must conform to
+	Format map[string]any `json:"format,omitempty"`
+}
+
+// OllamaOptions holds the model parameters of an Ollama request
+type OllamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// OllamaChatResponse represents a response, or one streamed line of a
+// response, from the Ollama chat API
+type OllamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done  bool   `json:"done"`
+	Error string `json:"error"`
+}
+
+// ollamaProvider talks to the native chat API of a local Ollama server
+type ollamaProvider struct {
+	url   string
+	model string
+}
+
+// newOllamaProvider creates the provider for a local Ollama server, which
+// needs no API key
+func newOllamaProvider(cfg *config.Config) (Provider, error) {
+	return &ollamaProvider{
+		url:   hostURL(cfg.OllamaHost) + "/api/chat",
+		model: cfg.OllamaModel,
+	}, nil
+}
+
+// hostURL normalizes a host setting such as "localhost:11434" into a base URL
+func hostURL(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimRight(host, "/")
+}
+
+// Name returns the name the provider is registered under
+func (p *ollamaProvider) Name() string {
+	return "ollama"
+}
+
+// Capabilities reports the optional features the provider supports
+func (p *ollamaProvider) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, StructuredOutput: true}
+}
+
+// NewRequest builds the HTTP request for a completion
+func (p *ollamaProvider) NewRequest(req *CompletionRequest) (*http.Request, error) {
+	requestBody := OllamaChatRequest{
+		Model:    p.model,
+		Messages: req.Messages,
+		Stream:   req.Stream,
+		Options: OllamaOptions{
+			Temperature: req.Temperature,
+			NumPredict:  req.MaxTokens,
+		},
+	}
+	if req.Schema != nil {
+		requestBody.Format = req.Schema.Definition
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", p.url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// ParseResponse decodes a successful HTTP response into a completion
+func (p *ollamaProvider) ParseResponse(resp *http.Response) (*Completion, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var chatResponse OllamaChatResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		return nil, err
+	}
+	if chatResponse.Error != "" {
+		return nil, errors.New(chatResponse.Error)
+	}
+
+	return &Completion{Choices: []string{chatResponse.Message.Content}}, nil
+}
+
+// ParseStream decodes a streamed HTTP response, calling onDelta with the
+// choice index and each fragment of content as it arrives. Ollama streams one
+// JSON object per line rather than server-sent events.
+func (p *ollamaProvider) ParseStream(resp *http.Response, onDelta func(index int, delta string)) (*Completion, error) {
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk OllamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return nil, err
+		}
+		if chunk.Error != "" {
+			return nil, errors.New(chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				onDelta(0, chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Completion{Choices: []string{content.String()}}, nil
+}
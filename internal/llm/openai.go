@@ -0,0 +1,245 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func init() {
+	Register("openai", newOpenAIProvider)
+}
+
+// ChatRequest represents a chat completion request
+type ChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat constrains the output of a chat completion to JSON
+type ResponseFormat struct {
+	// Type is "json_schema" to enforce the schema or "json_object" for
+	// any JSON object
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat holds the schema of a "json_schema" response format
+type JSONSchemaFormat struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+// ChatResponse represents a chat completion response
+type ChatResponse struct {
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// ChatStreamChunk represents one server-sent event of a streamed chat completion
+type ChatStreamChunk struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// chatCompletionsProvider talks to any backend implementing the OpenAI chat
+// completions API
+type chatCompletionsProvider struct {
+	name         string
+	url          string
+	model        string
+	headers      map[string]string
+	capabilities Capabilities
+
+	// jsonMode asks for any JSON object instead of enforcing the schema, for
+	// models and API versions without json_schema support
+	jsonMode bool
+}
+
+// newOpenAIProvider creates the provider for the OpenAI API
+func newOpenAIProvider(cfg *config.Config) (Provider, error) {
+	if cfg.OpenAIAPIKey == "" {
+		return nil, &MissingAPIKeyError{Provider: "openai", EnvVar: "OPENAI_API_KEY"}
+	}
+
+	apiURL := "https://api.openai.com/v1/chat/completions"
+	if cfg.OpenAIAPIBase != "" {
+		apiURL = cfg.OpenAIAPIBase + "/v1/chat/completions"
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + cfg.OpenAIAPIKey,
+	}
+	if cfg.OpenAIOrganization != "" {
+		headers["OpenAI-Organization"] = cfg.OpenAIOrganization
+	}
+
+	return &chatCompletionsProvider{
+		name:    "openai",
+		url:     apiURL,
+		model:   cfg.OpenAIModel,
+		headers: headers,
+		capabilities: Capabilities{
+			Streaming:        true,
+			StructuredOutput: true,
+		},
+		jsonMode: !supportsJSONSchema(cfg.OpenAIModel),
+	}, nil
+}
+
+// supportsJSONSchema reports whether an OpenAI model supports the json_schema
+// response format, which arrived with gpt-4o. Older models only have JSON mode.
+func supportsJSONSchema(model string) bool {
+	return !strings.HasPrefix(model, "gpt-3.5") && !strings.HasPrefix(model, "gpt-4-") && model != "gpt-4"
+}
+
+// Name returns the name the provider is registered under
+func (p *chatCompletionsProvider) Name() string {
+	return p.name
+}
+
+// Capabilities reports the optional features the provider supports
+func (p *chatCompletionsProvider) Capabilities() Capabilities {
+	return p.capabilities
+}
+
+// NewRequest builds the HTTP request for a completion
+func (p *chatCompletionsProvider) NewRequest(req *CompletionRequest) (*http.Request, error) {
+	requestBody := ChatRequest{
+		Model:       p.model,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stream:      req.Stream,
+	}
+	if req.Schema != nil && p.capabilities.StructuredOutput {
+		requestBody.ResponseFormat = p.responseFormat(req.Schema)
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", p.url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, value := range p.headers {
+		httpReq.Header.Set(key, value)
+	}
+	return httpReq, nil
+}
+
+// responseFormat returns the response format constraining the completion to
+// the schema
+func (p *chatCompletionsProvider) responseFormat(schema *Schema) *ResponseFormat {
+	if p.jsonMode {
+		return &ResponseFormat{Type: "json_object"}
+	}
+	return &ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &JSONSchemaFormat{
+			Name:   schema.Name,
+			Strict: true,
+			Schema: schema.Definition,
+		},
+	}
+}
+
+// ParseResponse decodes a successful HTTP response into a completion
+func (p *chatCompletionsProvider) ParseResponse(resp *http.Response) (*Completion, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var chatResponse ChatResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		return nil, err
+	}
+
+	// Check if we have choices
+	if len(chatResponse.Choices) == 0 {
+		return nil, errors.New("no completions returned from API")
+	}
+
+	choices := make([]string, len(chatResponse.Choices))
+	for i, choice := range chatResponse.Choices {
+		index := choice.Index
+		if index < 0 || index >= len(choices) {
+			index = i
+		}
+		choices[index] = choice.Message.Content
+	}
+
+	return &Completion{Choices: choices}, nil
+}
+
+// ParseStream decodes a streamed HTTP response, calling onDelta with the
+// choice index and each fragment of content as it arrives
+func (p *chatCompletionsProvider) ParseStream(resp *http.Response, onDelta func(index int, delta string)) (*Completion, error) {
+	var choices []*strings.Builder
+
+	err := readSSE(resp.Body, func(event, data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, err
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 {
+				continue
+			}
+			for len(choices) <= choice.Index {
+				choices = append(choices, &strings.Builder{})
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			choices[choice.Index].WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Index, choice.Delta.Content)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(choices) == 0 {
+		return nil, errors.New("no completions returned from API")
+	}
+
+	completion := &Completion{Choices: make([]string, len(choices))}
+	for i := range choices {
+		completion.Choices[i] = choices[i].String()
+	}
+	return completion, nil
+}
@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+// Provider is an LLM backend that can serve chat completions
+type Provider interface {
+	// Name returns the name the provider is registered under
+	Name() string
+
+	// Capabilities reports the optional features the provider supports
+	Capabilities() Capabilities
+
+	// NewRequest builds the HTTP request for a completion
+	NewRequest(req *CompletionRequest) (*http.Request, error)
+
+	// ParseResponse decodes a successful HTTP response into a completion
+	ParseResponse(resp *http.Response) (*Completion, error)
+}
+
+// StreamingProvider is implemented by providers that can stream completions.
+// Providers reporting the Streaming capability must implement it.
+type StreamingProvider interface {
+	Provider
+
+	// ParseStream decodes a streamed HTTP response, calling onDelta with the
+	// choice index and each fragment of content as it arrives
+	ParseStream(resp *http.Response, onDelta func(index int, delta string)) (*Completion, error)
+}
+
+// Capabilities describes the optional features of a provider
+type Capabilities struct {
+	// Streaming is true if the provider can stream completions as server-sent events
+	Streaming bool
+
+	// StructuredOutput is true if the provider can be made to respond with a
+	// bare JSON object, through a response format or by calling a tool
+	StructuredOutput bool
+}
+
+// Schema is a JSON schema the response of a completion must conform to
+type Schema struct {
+	// Name identifies the schema, it is used as the tool name by providers
+	// that implement structured output with tool calling
+	Name        string
+	Description string
+	Definition  map[string]any
+}
+
+// CompletionRequest is a provider independent chat completion request
+type CompletionRequest struct {
+	Messages    []Message
+	Temperature float64
+	MaxTokens   int
+
+	// Stream asks the provider to stream the completion, OnDelta is called
+	// with the choice index and each fragment of content as it arrives
+	Stream  bool
+	OnDelta func(index int, delta string)
+
+	// Schema asks providers with the StructuredOutput capability to respond
+	// with a JSON object conforming to it
+	Schema *Schema
+}
+
+// Completion is a provider independent chat completion result
+type Completion struct {
+	Choices []string
+
+	// Provider is the name of the provider that produced the completion
+	Provider string
+
+	// Structured is true if each choice is a bare JSON object produced with
+	// structured output rather than free form text
+	Structured bool
+}
+
+// Content returns the content of the first choice
+func (c *Completion) Content() string {
+	if len(c.Choices) == 0 {
+		return ""
+	}
+	return c.Choices[0]
+}
+
+// MissingAPIKeyError is returned when the selected provider needs an API key
+// that is not configured
+type MissingAPIKeyError struct {
+	Provider string
+	EnvVar   string
+}
+
+func (e *MissingAPIKeyError) Error() string {
+	return fmt.Sprintf("the %s provider requires an API key, set %s", e.Provider, e.EnvVar)
+}
+
+// ProviderFactory creates a provider from the application configuration
+type ProviderFactory func(cfg *config.Config) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]ProviderFactory)
+)
+
+// Register makes a provider available under the given name. It panics if a
+// provider is already registered under that name.
+func Register(name string, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("llm: provider %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Providers returns the names of all registered providers
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ErrUnsupportedProvider is returned for a provider name that is not
+// registered
+var ErrUnsupportedProvider = errors.New("unsupported API provider")
+
+// NewProvider creates the provider registered under the given name
+func NewProvider(name string, cfg *config.Config) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s, use one of %s", ErrUnsupportedProvider, name, strings.Join(Providers(), ", "))
+	}
+	return factory(cfg)
+}
+
+// send builds the request for the provider, sends it and decodes the response.
+// The request is aborted when the context is done.
+func send(ctx context.Context, httpClient *http.Client, provider Provider, req *CompletionRequest) (*Completion, error) {
+	httpReq, err := provider.NewRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, &APIError{Kind: ErrorNetwork, Err: err}
+	}
+	defer resp.Body.Close()
+
+	// Check for errors
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(resp, body)
+	}
+
+	var completion *Completion
+	if streamer, ok := provider.(StreamingProvider); ok && req.Stream {
+		completion, err = streamer.ParseStream(resp, req.OnDelta)
+	} else {
+		completion, err = provider.ParseResponse(resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if completion == nil || len(completion.Choices) == 0 {
+		return nil, errors.New("no completions returned from API")
+	}
+	completion.Structured = req.Schema != nil && provider.Capabilities().StructuredOutput
+	return completion, nil
+}
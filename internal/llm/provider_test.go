@@ -0,0 +1,392 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+// newTestServer starts a server that records the last request it received and
+// replies with the given status and body
+func newTestServer(t *testing.T, status int, body string, got *http.Request, gotBody *[]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = *r.Clone(r.Context())
+		*gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":"{\"command\": \"ls\"}"}}]}`, &got, &gotBody)
+
+	cfg := &config.Config{
+		APIProvider:        "openai",
+		OpenAIAPIKey:       "test-key",
+		OpenAIModel:        "test-model",
+		OpenAIAPIBase:      srv.URL,
+		OpenAIOrganization: "test-org",
+		OpenAIMaxTokens:    42,
+		Temperature:        0.5,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	content, err := client.GenerateCompletion(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("GenerateCompletion() error = %v", err)
+	}
+	if content != `{"command": "ls"}` {
+		t.Errorf("GenerateCompletion() = %q, want %q", content, `{"command": "ls"}`)
+	}
+
+	if got.URL.Path != "/v1/chat/completions" {
+		t.Errorf("request path = %q, want %q", got.URL.Path, "/v1/chat/completions")
+	}
+	if h := got.Header.Get("Authorization"); h != "Bearer test-key" {
+		t.Errorf("Authorization header = %q, want %q", h, "Bearer test-key")
+	}
+	if h := got.Header.Get("OpenAI-Organization"); h != "test-org" {
+		t.Errorf("OpenAI-Organization header = %q, want %q", h, "test-org")
+	}
+
+	var req ChatRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if req.Model != "test-model" || req.MaxTokens != 42 || req.Temperature != 0.5 {
+		t.Errorf("request body = %+v, want model test-model, max tokens 42, temperature 0.5", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Content != "user" {
+		t.Errorf("request messages = %+v, want system and user messages", req.Messages)
+	}
+}
+
+func TestGroqProvider(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":"pwd"}}]}`, &got, &gotBody)
+
+	cfg := &config.Config{
+		APIProvider: "groq",
+		GroqAPIKey:  "groq-key",
+		GroqModel:   "groq-model",
+	}
+
+	provider, err := NewProvider(cfg.APIProvider, cfg)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	provider.(*chatCompletionsProvider).url = srv.URL
+
+	completion, err := send(context.Background(), srv.Client(), provider, &CompletionRequest{
+		Messages: []Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if completion.Content() != "pwd" {
+		t.Errorf("send() = %q, want %q", completion.Content(), "pwd")
+	}
+	if h := got.Header.Get("Authorization"); h != "Bearer groq-key" {
+		t.Errorf("Authorization header = %q, want %q", h, "Bearer groq-key")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "error status", status: http.StatusInternalServerError, body: `{"error": "boom"}`},
+		{name: "no choices", status: http.StatusOK, body: `{"choices": []}`},
+		{name: "invalid body", status: http.StatusOK, body: `not json`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Request
+			var gotBody []byte
+			srv := newTestServer(t, tt.status, tt.body, &got, &gotBody)
+
+			provider, err := newOpenAIProvider(&config.Config{OpenAIAPIKey: "test-key", OpenAIAPIBase: srv.URL})
+			if err != nil {
+				t.Fatalf("newOpenAIProvider() error = %v", err)
+			}
+
+			if _, err := send(context.Background(), srv.Client(), provider, &CompletionRequest{}); err == nil {
+				t.Errorf("send() expected an error")
+			}
+		})
+	}
+}
+
+func TestNewProviderUnsupported(t *testing.T) {
+	if _, err := NewProvider("does-not-exist", &config.Config{}); err == nil {
+		t.Errorf("NewProvider() expected an error for an unknown provider")
+	}
+
+	names := Providers()
+	for _, want := range []string{"groq", "openai"} {
+		found := false
+		for _, name := range names {
+			if name == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Providers() = %v, missing %q", names, want)
+		}
+	}
+}
+
+func TestOpenAIStreaming(t *testing.T) {
+	stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+		": keep-alive\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"{\\\"command\\\": \"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"\\\"ls\\\"}\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, stream, &got, &gotBody)
+
+	cfg := &config.Config{
+		APIProvider:   "openai",
+		OpenAIAPIKey:  "test-key",
+		OpenAIAPIBase: srv.URL,
+		Stream:        true,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	var deltas []string
+	content, err := client.StreamCompletion(context.Background(), "system", "user", func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("StreamCompletion() error = %v", err)
+	}
+
+	if content != `{"command": "ls"}` {
+		t.Errorf("StreamCompletion() = %q, want %q", content, `{"command": "ls"}`)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("StreamCompletion() called onDelta %d times, want 2", len(deltas))
+	}
+
+	var req ChatRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if !req.Stream {
+		t.Errorf("request body did not ask for a stream")
+	}
+}
+
+func TestStreamShellCommands(t *testing.T) {
+	// Providers that support multiple choices are asked for a list as well,
+	// separate choices of the same prompt come back as the same command
+	for _, provider := range []string{"openai", "groq"} {
+		t.Run(provider, func(t *testing.T) {
+			var got http.Request
+			var gotBody []byte
+			srv := newTestServer(t, http.StatusOK, `{"choices":[{"index":0,"message":{"content":"{\"commands\": [\"ls\", \"ls -la\", \"dir\"]}"}}]}`, &got, &gotBody)
+
+			cfg := &config.Config{
+				APIProvider:   provider,
+				OpenAIAPIKey:  "test-key",
+				OpenAIAPIBase: srv.URL,
+				GroqAPIKey:    "test-key",
+			}
+			client, err := NewClient(cfg)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			client.providers[0].(*chatCompletionsProvider).url = srv.URL + "/v1/chat/completions"
+
+			completion, err := client.StreamShellCommands(context.Background(), "list files", 3, nil)
+			if err != nil {
+				t.Fatalf("StreamShellCommands() error = %v", err)
+			}
+			if completion.Provider != provider {
+				t.Errorf("StreamShellCommands() provider = %q, want %q", completion.Provider, provider)
+			}
+			if commands, err := completion.Commands(); err != nil || len(commands) != 3 {
+				t.Errorf("Commands() = %+v, %v, want the 3 listed commands", commands, err)
+			}
+
+			var req ChatRequest
+			if err := json.Unmarshal(gotBody, &req); err != nil {
+				t.Fatalf("Failed to decode request body: %v", err)
+			}
+			if strings.Contains(string(gotBody), `"n":`) {
+				t.Errorf("request = %s, want a single choice", gotBody)
+			}
+			if last := req.Messages[len(req.Messages)-1].Content; !strings.Contains(last, "3 distinct shell commands") {
+				t.Errorf("request = %q, want a list of 3 distinct commands", last)
+			}
+		})
+	}
+}
+
+func TestAzureProvider(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":"whoami"}}]}`, &got, &gotBody)
+
+	cfg := &config.Config{
+		APIProvider:      "azure",
+		OpenAIAPIKey:     "azure-key",
+		OpenAIAPIBase:    srv.URL + "/",
+		OpenAIAPIVersion: "2024-02-01",
+		AzureDeployment:  "my deployment",
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	content, err := client.GenerateCompletion(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("GenerateCompletion() error = %v", err)
+	}
+	if content != "whoami" {
+		t.Errorf("GenerateCompletion() = %q, want %q", content, "whoami")
+	}
+
+	if got.URL.Path != "/openai/deployments/my deployment/chat/completions" {
+		t.Errorf("request path = %q, want %q", got.URL.Path, "/openai/deployments/my deployment/chat/completions")
+	}
+	if v := got.URL.Query().Get("api-version"); v != "2024-02-01" {
+		t.Errorf("api-version = %q, want %q", v, "2024-02-01")
+	}
+	if h := got.Header.Get("api-key"); h != "azure-key" {
+		t.Errorf("api-key header = %q, want %q", h, "azure-key")
+	}
+	if h := got.Header.Get("Authorization"); h != "" {
+		t.Errorf("Authorization header = %q, want it unset", h)
+	}
+
+	// The endpoint and deployment are required
+	if _, err := newAzureProvider(&config.Config{AzureDeployment: "d"}); err == nil {
+		t.Errorf("newAzureProvider() expected an error without OPENAI_API_BASE")
+	}
+	if _, err := newAzureProvider(&config.Config{OpenAIAPIBase: srv.URL}); err == nil {
+		t.Errorf("newAzureProvider() expected an error without AZURE_OPENAI_DEPLOYMENT")
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	for _, provider := range []string{"openai", "groq", "azure", "anthropic"} {
+		_, err := NewProvider(provider, &config.Config{OpenAIAPIBase: "http://localhost", AzureDeployment: "d"})
+		var keyErr *MissingAPIKeyError
+		if !errors.As(err, &keyErr) {
+			t.Errorf("NewProvider(%q) error = %v, want a MissingAPIKeyError", provider, err)
+		}
+	}
+
+	// Local providers do not need a key
+	for _, provider := range []string{"ollama", "local"} {
+		if _, err := NewProvider(provider, &config.Config{}); err != nil {
+			t.Errorf("NewProvider(%q) error = %v", provider, err)
+		}
+	}
+}
+
+func TestOllamaProvider(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"message":{"role":"assistant","content":"{\"command\": \"df -h\"}"},"done":true}`, &got, &gotBody)
+
+	cfg := &config.Config{
+		APIProvider:     "ollama",
+		OllamaHost:      srv.URL,
+		OllamaModel:     "test-model",
+		OpenAIMaxTokens: 64,
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	content, err := client.GenerateCompletion(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("GenerateCompletion() error = %v", err)
+	}
+	if content != `{"command": "df -h"}` {
+		t.Errorf("GenerateCompletion() = %q, want %q", content, `{"command": "df -h"}`)
+	}
+	if got.URL.Path != "/api/chat" {
+		t.Errorf("request path = %q, want %q", got.URL.Path, "/api/chat")
+	}
+
+	var req OllamaChatRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if req.Model != "test-model" || req.Stream || req.Options.NumPredict != 64 {
+		t.Errorf("request body = %+v, want model test-model, no stream, num_predict 64", req)
+	}
+}
+
+func TestOllamaStreaming(t *testing.T) {
+	stream := `{"message":{"content":"{\"command\": "},"done":false}` + "\n" +
+		`{"message":{"content":"\"free -m\"}"},"done":false}` + "\n" +
+		`{"message":{"content":""},"done":true}` + "\n"
+
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, stream, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{APIProvider: "ollama", OllamaHost: srv.URL, Stream: true})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	var deltas int
+	content, err := client.StreamCompletion(context.Background(), "system", "user", func(string) { deltas++ })
+	if err != nil {
+		t.Fatalf("StreamCompletion() error = %v", err)
+	}
+	if content != `{"command": "free -m"}` {
+		t.Errorf("StreamCompletion() = %q, want %q", content, `{"command": "free -m"}`)
+	}
+	if deltas != 2 {
+		t.Errorf("StreamCompletion() called onDelta %d times, want 2", deltas)
+	}
+}
+
+func TestHostURL(t *testing.T) {
+	tests := map[string]string{
+		"localhost:11434":         "http://localhost:11434",
+		"http://127.0.0.1:11434/": "http://127.0.0.1:11434",
+		"https://ollama.internal": "https://ollama.internal",
+	}
+	for host, want := range tests {
+		if got := hostURL(host); got != want {
+			t.Errorf("hostURL(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies why an API request failed
+type ErrorKind int
+
+// Error kinds
+const (
+	ErrorUnknown ErrorKind = iota
+	ErrorRateLimit
+	ErrorOverloaded
+	ErrorServer
+	ErrorNetwork
+	ErrorAuth
+	ErrorBadRequest
+)
+
+// String returns a human readable name for the error kind
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorRateLimit:
+		return "rate limited"
+	case ErrorOverloaded:
+		return "overloaded"
+	case ErrorServer:
+		return "server error"
+	case ErrorNetwork:
+		return "network error"
+	case ErrorAuth:
+		return "authentication error"
+	case ErrorBadRequest:
+		return "bad request"
+	default:
+		return "unknown error"
+	}
+}
+
+// APIError is returned when a request to a provider fails
+type APIError struct {
+	Kind       ErrorKind
+	StatusCode int
+	Message    string
+
+	// RetryAfter is how long the provider asked us to wait before retrying,
+	// zero if it did not say
+	RetryAfter time.Duration
+
+	// Err is the underlying error for network failures
+	Err error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("API request failed: %v", e.Err)
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API request failed (%s): %s", e.Kind, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the request may succeed if it is sent again
+func (e *APIError) Retryable() bool {
+	switch e.Kind {
+	case ErrorRateLimit, ErrorOverloaded, ErrorServer, ErrorNetwork:
+		return true
+	default:
+		return false
+	}
+}
+
+// newStatusError creates the error for a response with a non-200 status
+func newStatusError(resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Kind:       classifyStatus(resp.StatusCode),
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+		RetryAfter: parseRetryAfter(resp.Header, resp.StatusCode, time.Now()),
+	}
+}
+
+// classifyStatus maps an HTTP status code to an error kind
+func classifyStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	// 529 is used by Anthropic when the API is overloaded
+	case status == http.StatusServiceUnavailable || status == 529:
+		return ErrorOverloaded
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorAuth
+	case status == http.StatusRequestTimeout:
+		return ErrorServer
+	case status >= 500:
+		return ErrorServer
+	case status >= 400:
+		return ErrorBadRequest
+	default:
+		return ErrorUnknown
+	}
+}
+
+// parseRetryAfter returns how long the response headers ask us to wait. It
+// understands Retry-After in seconds or as an HTTP date, retry-after-ms, and
+// for rate limited responses the x-ratelimit-reset family of headers used by
+// OpenAI compatible APIs, which hold either durations like "2m59.56s" or
+// seconds. Those headers are sent with every response to report the limits,
+// so for other errors they do not say when to retry.
+func parseRetryAfter(header http.Header, status int, now time.Time) time.Duration {
+	if val := header.Get("retry-after-ms"); val != "" {
+		if ms, err := strconv.ParseFloat(val, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	if val := header.Get("Retry-After"); val != "" {
+		if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 {
+			return time.Duration(secs * float64(time.Second))
+		}
+		if date, err := http.ParseTime(val); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+
+	if status != http.StatusTooManyRequests {
+		return 0
+	}
+
+	// Wait for the limit that resets last
+	var wait time.Duration
+	for _, name := range []string{"x-ratelimit-reset", "x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if d := parseReset(header.Get(name), now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// parseReset parses a rate limit reset header value
+func parseReset(val string, now time.Time) time.Duration {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0
+	}
+
+	if d, err := time.ParseDuration(val); err == nil && d > 0 {
+		return d
+	}
+
+	if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 {
+		// Large values are unix timestamps rather than relative seconds
+		if secs > 1e9 {
+			reset := time.Unix(int64(secs), 0)
+			if reset.After(now) {
+				return reset.Sub(now)
+			}
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	return 0
+}
+
+// retryPolicy decides whether and when failed requests are sent again
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+
+	// maxRetryAfter is the longest wait requested by a provider that we
+	// honor, if a provider asks for more we give up instead
+	maxRetryAfter time.Duration
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// newRetryPolicy creates the retry policy with the given number of attempts
+func newRetryPolicy(maxAttempts int) retryPolicy {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return retryPolicy{
+		maxAttempts:   maxAttempts,
+		baseDelay:     500 * time.Millisecond,
+		maxDelay:      8 * time.Second,
+		maxRetryAfter: 30 * time.Second,
+		sleep:         sleepContext,
+	}
+}
+
+// sleepContext waits for the given duration, returning early with the error of
+// the context if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay returns how long to wait before the given retry, or false if the
+// request should not be retried
+func (p retryPolicy) delay(retry int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Retryable() {
+		return 0, false
+	}
+
+	if apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > p.maxRetryAfter {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	// Exponential backoff with full jitter
+	backoff := p.baseDelay << retry
+	if backoff <= 0 || backoff > p.maxDelay {
+		backoff = p.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(backoff))) + time.Millisecond, true
+}
+
+// do calls fn until it succeeds, fails with an error that is not retryable,
+// the attempts are used up or the context is done. onRetry is called before
+// waiting for each retry.
+func (p retryPolicy) do(ctx context.Context, fn func() (*Completion, error), onRetry func(wait time.Duration, err error)) (*Completion, error) {
+	for attempt := 1; ; attempt++ {
+		completion, err := fn()
+		if err == nil || attempt >= p.maxAttempts {
+			return completion, err
+		}
+
+		wait, ok := p.delay(attempt-1, err)
+		if !ok {
+			return nil, err
+		}
+		if onRetry != nil {
+			onRetry(wait, err)
+		}
+		if err := p.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
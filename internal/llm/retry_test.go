@@ -0,0 +1,196 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   ErrorKind
+	}{
+		{status: 429, want: ErrorRateLimit},
+		{status: 503, want: ErrorOverloaded},
+		{status: 529, want: ErrorOverloaded},
+		{status: 500, want: ErrorServer},
+		{status: 502, want: ErrorServer},
+		{status: 401, want: ErrorAuth},
+		{status: 403, want: ErrorAuth},
+		{status: 400, want: ErrorBadRequest},
+		{status: 404, want: ErrorBadRequest},
+	}
+
+	for _, tt := range tests {
+		if got := classifyStatus(tt.status); got != tt.want {
+			t.Errorf("classifyStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		want   time.Duration
+	}{
+		{name: "none", header: map[string]string{}, want: 0},
+		{name: "seconds", header: map[string]string{"Retry-After": "2"}, want: 2 * time.Second},
+		{name: "http date", header: map[string]string{"Retry-After": "Mon, 01 Jan 2024 12:00:05 GMT"}, want: 5 * time.Second},
+		{name: "milliseconds", header: map[string]string{"retry-after-ms": "250"}, want: 250 * time.Millisecond},
+		{name: "reset duration", header: map[string]string{"x-ratelimit-reset-requests": "1m2.5s"}, want: 62500 * time.Millisecond},
+		{name: "latest reset", header: map[string]string{"x-ratelimit-reset-requests": "2s", "x-ratelimit-reset-tokens": "7.66s"}, want: 7660 * time.Millisecond},
+		{name: "reset seconds", header: map[string]string{"x-ratelimit-reset": "3"}, want: 3 * time.Second},
+		{name: "reset timestamp", header: map[string]string{"x-ratelimit-reset": "1704110410"}, want: 10 * time.Second},
+		{name: "garbage", header: map[string]string{"Retry-After": "soon"}, want: 0},
+		{name: "reset on server error", status: http.StatusInternalServerError, header: map[string]string{"x-ratelimit-reset-tokens": "1m30s"}, want: 0},
+		{name: "retry after on server error", status: http.StatusServiceUnavailable, header: map[string]string{"Retry-After": "2", "x-ratelimit-reset-tokens": "1m30s"}, want: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+			status := tt.status
+			if status == 0 {
+				status = http.StatusTooManyRequests
+			}
+			if got := parseRetryAfter(header, status, now); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := newRetryPolicy(3)
+
+	if _, ok := policy.delay(0, &APIError{Kind: ErrorAuth}); ok {
+		t.Errorf("delay() should not retry authentication errors")
+	}
+	if _, ok := policy.delay(0, errors.New("plain error")); ok {
+		t.Errorf("delay() should not retry errors that are not API errors")
+	}
+	if _, ok := policy.delay(0, &APIError{Kind: ErrorRateLimit, RetryAfter: time.Hour}); ok {
+		t.Errorf("delay() should give up when asked to wait longer than %v", policy.maxRetryAfter)
+	}
+	if wait, ok := policy.delay(0, &APIError{Kind: ErrorRateLimit, RetryAfter: 2 * time.Second}); !ok || wait != 2*time.Second {
+		t.Errorf("delay() = %v, %v, want the requested 2s", wait, ok)
+	}
+
+	// Jittered backoff stays below the cap
+	for retry := 0; retry < 10; retry++ {
+		wait, ok := policy.delay(retry, &APIError{Kind: ErrorServer})
+		if !ok || wait <= 0 || wait > policy.maxDelay+time.Millisecond {
+			t.Errorf("delay(%d) = %v, %v, want a wait in (0, %v]", retry, wait, ok, policy.maxDelay)
+		}
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "rate limited then ok", statuses: []int{429, 200}, maxAttempts: 3, wantRequests: 2},
+		{name: "overloaded twice then ok", statuses: []int{529, 503, 200}, maxAttempts: 3, wantRequests: 3},
+		{name: "attempts used up", statuses: []int{500, 500, 500, 200}, maxAttempts: 3, wantErr: true, wantRequests: 3},
+		{name: "auth error not retried", statuses: []int{401, 200}, maxAttempts: 3, wantErr: true, wantRequests: 1},
+		{name: "retries disabled", statuses: []int{429, 200}, maxAttempts: 1, wantErr: true, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[requests]
+				requests++
+				if status != http.StatusOK {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(status)
+					return
+				}
+				io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+			}))
+			defer srv.Close()
+
+			client, err := NewClient(&config.Config{
+				APIProvider:   "openai",
+				OpenAIAPIKey:  "test-key",
+				OpenAIAPIBase: srv.URL,
+				MaxAttempts:   tt.maxAttempts,
+			})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			var waits []time.Duration
+			client.retry.sleep = func(_ context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			_, err = client.GenerateCompletion(context.Background(), "system", "user")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateCompletion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", requests, tt.wantRequests)
+			}
+			for _, wait := range waits {
+				if wait != time.Second {
+					t.Errorf("waited %v between attempts, want the Retry-After of 1s", wait)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRetriesServerErrorWithRateLimitHeaders(t *testing.T) {
+	// OpenAI and Groq report their rate limits on every response, a server
+	// error is retried regardless of when the limits reset
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("x-ratelimit-reset-tokens", "1m30s")
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(&config.Config{
+		APIProvider:   "openai",
+		OpenAIAPIKey:  "test-key",
+		OpenAIAPIBase: srv.URL,
+		MaxAttempts:   3,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.retry.sleep = func(context.Context, time.Duration) error { return nil }
+
+	if _, err := client.GenerateCompletion(context.Background(), "system", "user"); err != nil {
+		t.Errorf("GenerateCompletion() error = %v, want the server error to be retried", err)
+	}
+	if requests != 2 {
+		t.Errorf("server received %d requests, want 2", requests)
+	}
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxSSELineSize is the longest server-sent event line we accept
+const maxSSELineSize = 1024 * 1024
+
+// readSSE reads a server-sent event stream and calls fn with the event name
+// and data of each event. Reading stops at the end of the stream or when fn
+// returns an error, which is passed back to the caller. A nil error from fn
+// together with done set to true stops reading without an error.
+func readSSE(r io.Reader, fn func(event, data string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var event string
+	var data []string
+
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			event = ""
+			return false, nil
+		}
+		done, err := fn(event, strings.Join(data, "\n"))
+		event = ""
+		data = data[:0]
+		return done, err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A blank line dispatches the event
+		if line == "" {
+			if done, err := dispatch(); done || err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Lines starting with a colon are comments
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Dispatch a trailing event that was not followed by a blank line
+	_, err := dispatch()
+	return err
+}
@@ -0,0 +1,259 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/config"
+)
+
+func TestStructuredOutputRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      string
+		structured bool
+		wantFormat string
+	}{
+		{name: "json schema", model: "gpt-4o-mini", structured: true, wantFormat: "json_schema"},
+		{name: "json mode for older models", model: "gpt-3.5-turbo", structured: true, wantFormat: "json_object"},
+		{name: "disabled", model: "gpt-4o-mini", structured: false, wantFormat: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Request
+			var gotBody []byte
+			srv := newTestServer(t, http.StatusOK, `{"choices":[{"message":{"content":"{\"command\": \"ls\"}"}}]}`, &got, &gotBody)
+
+			client, err := NewClient(&config.Config{
+				APIProvider:      "openai",
+				OpenAIAPIKey:     "test-key",
+				OpenAIModel:      tt.model,
+				OpenAIAPIBase:    srv.URL,
+				StructuredOutput: tt.structured,
+			})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			command, err := client.GenerateShellCommand(context.Background(), "list files")
+			if err != nil {
+				t.Fatalf("GenerateShellCommand() error = %v", err)
+			}
+			if command.Command != "ls" {
+				t.Errorf("GenerateShellCommand() = %q, want %q", command.Command, "ls")
+			}
+
+			var req ChatRequest
+			if err := json.Unmarshal(gotBody, &req); err != nil {
+				t.Fatalf("Failed to decode request body: %v", err)
+			}
+			format := ""
+			if req.ResponseFormat != nil {
+				format = req.ResponseFormat.Type
+			}
+			if format != tt.wantFormat {
+				t.Errorf("request response_format = %q, want %q", format, tt.wantFormat)
+			}
+			if format == "json_schema" && (req.ResponseFormat.JSONSchema == nil || !req.ResponseFormat.JSONSchema.Strict) {
+				t.Errorf("request json_schema = %+v, want a strict schema", req.ResponseFormat.JSONSchema)
+			}
+		})
+	}
+}
+
+func TestAnthropicToolCalling(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"content":[{"type":"tool_use","name":"suggest_command","input":{"command":"uname -a"}}]}`, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{
+		APIProvider:      "anthropic",
+		AnthropicAPIKey:  "anthropic-key",
+		StructuredOutput: true,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.providers[0].(*anthropicProvider).url = srv.URL
+
+	command, err := client.GenerateShellCommand(context.Background(), "show the kernel version")
+	if err != nil {
+		t.Fatalf("GenerateShellCommand() error = %v", err)
+	}
+	if command.Command != "uname -a" {
+		t.Errorf("GenerateShellCommand() = %q, want %q", command.Command, "uname -a")
+	}
+
+	var req AnthropicRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Name != "suggest_command" {
+		t.Errorf("request tools = %+v, want the suggest_command tool", req.Tools)
+	}
+	if req.ToolChoice == nil || req.ToolChoice.Type != "tool" || req.ToolChoice.Name != "suggest_command" {
+		t.Errorf("request tool_choice = %+v, want the suggest_command tool to be forced", req.ToolChoice)
+	}
+}
+
+func TestAnthropicToolCallingStream(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	body := "event: content_block_start\n" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","name":"suggest_command","input":{}}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"command\": \"up"}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"time\"}"}}` + "\n\n" +
+		"event: message_stop\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+	srv := newTestServer(t, http.StatusOK, body, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{
+		APIProvider:      "anthropic",
+		AnthropicAPIKey:  "anthropic-key",
+		Stream:           true,
+		StructuredOutput: true,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.providers[0].(*anthropicProvider).url = srv.URL
+
+	deltas := 0
+	completion, err := client.StreamShellCommand(context.Background(), "show uptime", func(string) { deltas++ })
+	if err != nil {
+		t.Fatalf("StreamShellCommand() error = %v", err)
+	}
+	if !completion.Structured {
+		t.Errorf("StreamShellCommand() completion is not structured")
+	}
+	if deltas != 2 {
+		t.Errorf("StreamShellCommand() called onDelta %d times, want 2", deltas)
+	}
+
+	commands, err := completion.Commands()
+	if err != nil {
+		t.Fatalf("Commands() error = %v", err)
+	}
+	if len(commands) != 1 || commands[0].Command != "uptime" {
+		t.Errorf("Commands() = %+v, want uptime", commands)
+	}
+}
+
+func TestOllamaStructuredOutput(t *testing.T) {
+	var got http.Request
+	var gotBody []byte
+	srv := newTestServer(t, http.StatusOK, `{"message":{"content":"{\"commands\": [\"df -h\", \"du -sh .\"]}"},"done":true}`, &got, &gotBody)
+
+	client, err := NewClient(&config.Config{
+		APIProvider:      "ollama",
+		OllamaHost:       srv.URL,
+		StructuredOutput: true,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	completion, err := client.StreamShellCommands(context.Background(), "show disk usage", 2, nil)
+	if err != nil {
+		t.Fatalf("StreamShellCommands() error = %v", err)
+	}
+	commands, err := completion.Commands()
+	if err != nil {
+		t.Fatalf("Commands() error = %v", err)
+	}
+	if len(commands) != 2 {
+		t.Errorf("Commands() = %+v, want 2 commands", commands)
+	}
+
+	var req OllamaChatRequest
+	if err := json.Unmarshal(gotBody, &req); err != nil {
+		t.Fatalf("Failed to decode request body: %v", err)
+	}
+	if _, ok := req.Format["properties"].(map[string]any)["commands"]; !ok {
+		t.Errorf("request format = %v, want the schema of a list of commands", req.Format)
+	}
+}
+
+func TestCompletionCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		completion Completion
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "structured",
+			completion: Completion{Choices: []string{`{"command": "ls"}`}, Structured: true},
+			want:       []string{"ls"},
+		},
+		{
+			name:       "free form falls back to markdown",
+			completion: Completion{Choices: []string{"Sure!\n```json\n{\"command\": \"pwd\"}\n```"}},
+			want:       []string{"pwd"},
+		},
+		{
+			name:       "one command per choice",
+			completion: Completion{Choices: []string{`{"command": "ls"}`, `not json`, `{"command": "ls -a"}`}, Structured: true},
+			want:       []string{"ls", "ls -a"},
+		},
+		{
+			name:       "no commands",
+			completion: Completion{Choices: []string{`not json`}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.completion.Commands()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Commands() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Commands() = %+v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Command != tt.want[i] {
+					t.Errorf("Commands()[%d] = %q, want %q", i, got[i].Command, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCommandSchemaIsStrict(t *testing.T) {
+	// Strict structured output rejects objects with optional properties
+	var check func(path string, schema map[string]any)
+	check = func(path string, schema map[string]any) {
+		properties, ok := schema["properties"].(map[string]any)
+		if !ok {
+			if items, ok := schema["items"].(map[string]any); ok {
+				check(path+"[]", items)
+			}
+			return
+		}
+
+		required := map[string]bool{}
+		for _, name := range schema["required"].([]string) {
+			required[name] = true
+		}
+		for name, property := range properties {
+			if !required[name] {
+				t.Errorf("schema property %s.%s is not required", path, name)
+			}
+			check(path+"."+name, property.(map[string]any))
+		}
+		if schema["additionalProperties"] != false {
+			t.Errorf("schema %s allows additional properties", path)
+		}
+	}
+
+	for _, n := range []int{1, 3} {
+		check("$", commandSchema(n).Definition)
+	}
+}
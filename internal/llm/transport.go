@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/config"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newTransport creates the HTTP transport used for all outbound LLM traffic.
+// It routes requests through OPENAI_PROXY when set, bypassing it for loopback
+// addresses and NO_PROXY, falling back to the standard proxy environment
+// variables, and applies the custom CA bundle and
+// client certificate from the configuration. Connecting, including the TLS
+// handshake, is limited to SHAI_CONNECT_TIMEOUT seconds.
+func newTransport(cfg *config.Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ConnectTimeout > 0 {
+		timeout := time.Duration(cfg.ConnectTimeout) * time.Second
+		dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = timeout
+	}
+
+	if cfg.OpenAIProxy != "" {
+		proxyURL, err := parseProxyURL(cfg.OpenAIProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxyFunc(proxyURL, httpproxy.FromEnvironment().NoProxy)
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// proxyFunc routes requests through the proxy, except requests to loopback
+// addresses, such as a local model server, and to the hosts in noProxy, which
+// has the format of NO_PROXY
+func proxyFunc(proxyURL *url.URL, noProxy string) func(*http.Request) (*url.URL, error) {
+	// httpproxy only knows some proxy schemes, so it is given a stand-in
+	// proxy and only decides whether a request bypasses it
+	bypass := (&httpproxy.Config{
+		HTTPProxy:  "http://proxy",
+		HTTPSProxy: "http://proxy",
+		NoProxy:    noProxy,
+	}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		if via, err := bypass(req.URL); err != nil || via == nil {
+			return nil, err
+		}
+		return proxyURL, nil
+	}
+}
+
+// parseProxyURL parses the proxy setting, which may omit the scheme for HTTP
+// proxies
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", proxy)
+	}
+
+	return proxyURL, nil
+}
+
+// newTLSConfig creates the TLS configuration for the custom CA bundle and
+// client certificate, returning nil if neither is configured
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.CABundle == "" && cfg.ClientCert == "" && cfg.ClientKey == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %w", err)
+		}
+
+		// Trust the bundle in addition to the system roots
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, errors.New("SHAI_CLIENT_CERT and SHAI_CLIENT_KEY must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jwswj/shell-ai/internal/tokens"
+)
+
+// DefaultContextTokens is the context budget used until it is set for a model
+const DefaultContextTokens = 1500
+
+// lastTurnShare is the percentage of the budget the output of the last turn
+// may take when there are earlier turns
+const lastTurnShare = 75
+
+// olderOutputTokens is how many tokens of output are kept for each turn before
+// the last one
+const olderOutputTokens = 200
+
+// maxTurns is how many turns are remembered
+const maxTurns = 50
+
+// turnOverhead is how many tokens the text and message framing around a turn
+// take
+const turnOverhead = 30
+
+// Turn is one step of a context mode session: what the user asked for, the
+// command that was run and its result
+type Turn struct {
+	Prompt  string
+	Command string
+
+	// ExitCode is the exit status of the command, -1 if it could not be run
+	ExitCode int
+
+	Output string
+}
+
+// ContextManager keeps the history of a context mode session and picks as much
+// of it as fits a token budget for the LLM
+type ContextManager struct {
+	mu        sync.Mutex
+	turns     []Turn
+	encoding  tokens.Encoding
+	maxTokens int
+}
+
+// NewContextManager creates a new context manager
+func NewContextManager() *ContextManager {
+	return &ContextManager{
+		encoding:  tokens.CL100K,
+		maxTokens: DefaultContextTokens,
+	}
+}
+
+// SetBudget sets the tokenizer the context is measured with and how many
+// tokens it may take
+func (cm *ContextManager) SetBudget(encoding tokens.Encoding, maxTokens int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.encoding = encoding
+	cm.maxTokens = maxTokens
+}
+
+// AddTurn adds a step to the history, forgetting the oldest beyond maxTurns
+func (cm *ContextManager) AddTurn(turn Turn) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.turns = append(cm.turns, turn)
+	if len(cm.turns) > maxTurns {
+		cm.turns = cm.turns[len(cm.turns)-maxTurns:]
+	}
+}
+
+// Flush clears the context
+func (cm *ContextManager) Flush() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.turns = nil
+}
+
+// Window returns the turns that fit the budget, oldest first, and how many
+// older turns were left out. The last turn keeps the end of its output up to
+// most of the budget, earlier turns keep a short end of their output, and the
+// oldest turns are left out once the budget is spent.
+func (cm *ContextManager) Window() ([]Turn, int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	remaining := cm.maxTokens
+	var window []Turn
+	i := len(cm.turns) - 1
+	for ; i >= 0; i-- {
+		turn := cm.turns[i]
+		cost := turnOverhead + cm.encoding.Count(turn.Prompt) + cm.encoding.Count(turn.Command)
+		if cost > remaining {
+			break
+		}
+		remaining -= cost
+
+		// The last turn leaves room for the ones before it
+		limit := remaining
+		if i == len(cm.turns)-1 && i > 0 {
+			limit = min(limit, cm.maxTokens*lastTurnShare/100)
+		} else if i < len(cm.turns)-1 {
+			limit = min(limit, olderOutputTokens)
+		}
+		if output := strings.TrimRight(turn.Output, "\n"); output != "" {
+			if turn.Output = cm.truncate(output, limit); turn.Output == "" {
+				turn.Output = "[output left out]"
+			}
+		}
+		remaining -= cm.encoding.Count(turn.Output)
+
+		window = append(window, turn)
+	}
+
+	// The turns were collected newest first
+	for l, r := 0, len(window)-1; l < r; l, r = l+1, r-1 {
+		window[l], window[r] = window[r], window[l]
+	}
+	return window, i + 1
+}
+
+// truncate keeps the end of an output that fits limit tokens, noting how many
+// lines were left out
+func (cm *ContextManager) truncate(output string, limit int) string {
+	if cm.encoding.Count(output) <= limit {
+		return output
+	}
+
+	lines := strings.Split(output, "\n")
+	marker := func(n int) string { return fmt.Sprintf("[%d earlier lines left out]", n) }
+	used := cm.encoding.Count(marker(len(lines)))
+	kept := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		cost := cm.encoding.Count(lines[i]) + 1
+		if used+cost > limit {
+			break
+		}
+		used += cost
+		kept++
+	}
+
+	if kept == 0 {
+		// Not even the last line fits, keep its end
+		last := []rune(lines[len(lines)-1])
+		for len(last) > 0 && used+cm.encoding.Count(string(last)) > limit {
+			last = last[len(last)/4+1:]
+		}
+		if len(last) == 0 {
+			return ""
+		}
+		return marker(len(lines)-1) + "\n" + string(last)
+	}
+	return marker(len(lines)-kept) + "\n" + strings.Join(lines[len(lines)-kept:], "\n")
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/tokens"
+)
+
+func TestContextManager(t *testing.T) {
+	cm := NewContextManager()
+
+	// Test empty context
+	if turns, omitted := cm.Window(); len(turns) != 0 || omitted != 0 {
+		t.Errorf("Expected empty context, got %+v and %d left out", turns, omitted)
+	}
+
+	// Test adding turns, all of them are kept while they fit
+	first := Turn{Prompt: "list go files", Command: "ls *.go", Output: "main.go\nmain_test.go"}
+	second := Turn{Prompt: "edit it", Command: "vim main.go", ExitCode: 1}
+	cm.AddTurn(first)
+	cm.AddTurn(second)
+	turns, omitted := cm.Window()
+	if len(turns) != 2 || turns[0] != first || turns[1] != second || omitted != 0 {
+		t.Errorf("Window() = %+v, %d, want both turns", turns, omitted)
+	}
+
+	// Test flushing
+	cm.Flush()
+	if turns, _ := cm.Window(); len(turns) != 0 {
+		t.Errorf("Expected empty context after flush, got %+v", turns)
+	}
+}
+
+// windowTokens counts the tokens of the turns of a window
+func windowTokens(turns []Turn) int {
+	n := 0
+	for _, turn := range turns {
+		n += turnOverhead + tokens.CL100K.Count(turn.Prompt) + tokens.CL100K.Count(turn.Command) + tokens.CL100K.Count(turn.Output)
+	}
+	return n
+}
+
+func TestContextManagerBudget(t *testing.T) {
+	const budget = 400
+	cm := NewContextManager()
+	cm.SetBudget(tokens.CL100K, budget)
+
+	var long strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&long, "line %d of the output\n", i)
+	}
+	for i := 0; i < 10; i++ {
+		cm.AddTurn(Turn{Prompt: fmt.Sprintf("step %d", i), Command: fmt.Sprintf("cmd%d", i), Output: long.String()})
+	}
+
+	turns, omitted := cm.Window()
+	if n := windowTokens(turns); n > budget {
+		t.Errorf("Window() takes %d tokens, want at most %d", n, budget)
+	}
+
+	// The oldest turns are left out and counted
+	if len(turns) < 2 || len(turns)+omitted != 10 {
+		t.Fatalf("Window() kept %d turns and left out %d, want some of each adding up to 10", len(turns), omitted)
+	}
+	if first := turns[0].Command; first != fmt.Sprintf("cmd%d", omitted) {
+		t.Errorf("Window() starts at %s, want cmd%d", first, omitted)
+	}
+
+	// The last turn keeps the end of its output, noting what was left out
+	last := turns[len(turns)-1]
+	if last.Command != "cmd9" || !strings.HasSuffix(last.Output, "line 499 of the output") {
+		t.Errorf("Window() last turn = %+v, want cmd9 with the end of its output", last)
+	}
+	if !strings.HasPrefix(last.Output, "[") || !strings.Contains(last.Output, "earlier lines left out]") {
+		t.Errorf("Window() does not note the lines left out of the last turn:\n%s", last.Output)
+	}
+
+	// The turns kept are not changed
+	if turns, _ := cm.Window(); !strings.HasPrefix(cm.turns[9].Output, "line 0 of the output") || len(turns) == 0 {
+		t.Error("Window() changed the remembered output")
+	}
+}
+
+func TestContextManagerLongLine(t *testing.T) {
+	cm := NewContextManager()
+	cm.SetBudget(tokens.CL100K, 80)
+
+	cm.AddTurn(Turn{Prompt: "minified", Command: "cat app.min.js", Output: strings.Repeat("var a=1;", 500)})
+
+	turns, _ := cm.Window()
+	if len(turns) != 1 {
+		t.Fatalf("Window() = %+v, want the turn", turns)
+	}
+	if n := windowTokens(turns); n > 80 {
+		t.Errorf("Window() takes %d tokens, want at most 80:\n%s", n, turns[0].Output)
+	}
+	if !strings.HasSuffix(turns[0].Output, "var a=1;") {
+		t.Errorf("Window() does not keep the end of the line:\n%s", turns[0].Output)
+	}
+}
+
+func TestContextManagerMaxTurns(t *testing.T) {
+	cm := NewContextManager()
+	for i := 0; i < maxTurns+5; i++ {
+		cm.AddTurn(Turn{Prompt: "p", Command: fmt.Sprintf("c%d", i)})
+	}
+	if len(cm.turns) != maxTurns || cm.turns[0].Command != "c5" {
+		t.Errorf("kept %d turns starting at %s, want %d starting at c5", len(cm.turns), cm.turns[0].Command, maxTurns)
+	}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// CommandExplanation is the LLM's breakdown of an existing command
+type CommandExplanation struct {
+	// Command is the explained command, as echoed back by the LLM
+	Command string `json:"command"`
+
+	// Summary describes what the command does as a whole
+	Summary string `json:"summary"`
+
+	// Parts explain the command token by token
+	Parts []CommandPart `json:"parts"`
+}
+
+// CommandPart is a token or flag of an explained command
+type CommandPart struct {
+	Text        string `json:"text"`
+	Explanation string `json:"explanation"`
+}
+
+// ParseCommandExplanation parses a free form LLM response holding a command
+// explanation. The JSON is found with ExtractCommandJSON, so it may be wrapped
+// in prose or markdown and slightly malformed.
+func ParseCommandExplanation(response string) (*CommandExplanation, error) {
+	jsonContent, err := ExtractCommandJSON(response)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeCommandExplanation(jsonContent)
+}
+
+// DecodeCommandExplanation decodes a response that is known to be a bare JSON
+// object holding a command explanation
+func DecodeCommandExplanation(content string) (*CommandExplanation, error) {
+	var explanation CommandExplanation
+	if err := json.Unmarshal([]byte(content), &explanation); err != nil {
+		return nil, err
+	}
+	if explanation.Summary == "" && len(explanation.Parts) == 0 {
+		return nil, errors.New("no explanation in response")
+	}
+	return &explanation, nil
+}
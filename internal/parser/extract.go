@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoCommand is returned when a response holds no JSON object with a
+// "command" or "commands" key
+var ErrNoCommand = errors.New("no JSON object with a command found in response")
+
+// ExtractCommandJSON finds the first JSON object in a free form response that
+// holds a "command" or "commands" key and returns it as valid JSON. The object
+// may be surrounded by prose or markdown, nested in another object, and may be
+// malformed in the ways models commonly get wrong: trailing commas, single
+// quoted strings, unquoted keys, raw newlines inside strings and output that
+// was cut off before the object was closed.
+func ExtractCommandJSON(response string) (string, error) {
+	for start := strings.IndexByte(response, '{'); start >= 0; {
+		end, _ := scanObject(response, start)
+		candidate := repairJSON(response[start:end])
+		if hasCommandKey(candidate) {
+			return candidate, nil
+		}
+
+		// Look for the command in the objects nested in this one
+		next := strings.IndexByte(response[start+1:], '{')
+		if next < 0 {
+			break
+		}
+		start += 1 + next
+	}
+
+	return "", ErrNoCommand
+}
+
+// hasCommandKey reports whether s is a JSON object with a "command" or
+// "commands" key
+func hasCommandKey(s string) bool {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &object); err != nil {
+		return false
+	}
+	_, hasCommand := object["command"]
+	_, hasCommands := object["commands"]
+	return hasCommand || hasCommands
+}
+
+// scanObject returns the end of the object starting at s[start] and whether it
+// was closed, the end is the end of s if it was not. Braces inside single or
+// double quoted strings are ignored.
+func scanObject(s string, start int) (int, bool) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return len(s), false
+}
+
+// Phases of a JSON object or array being repaired
+const (
+	phaseKey   = iota // expecting a key, or a value in an array
+	phaseColon        // after a key, expecting a colon
+	phaseValue        // after a colon, expecting a value
+	phaseAfter        // after a value, expecting a comma or the end
+)
+
+// frame is an object or array that has been opened but not yet closed
+type frame struct {
+	open  byte
+	phase int
+
+	// start is the length of the output where the current member began
+	start int
+}
+
+// repairJSON rewrites a nearly valid JSON object into valid JSON. Single
+// quoted strings are converted to double quoted ones, control characters and
+// invalid escapes inside strings are escaped, unquoted keys are quoted,
+// trailing commas are dropped and keys without values, objects and arrays
+// left open by truncated output are closed. A member whose string was cut off
+// is dropped rather than closed, so a partial command is never returned.
+func repairJSON(s string) string {
+	var out strings.Builder
+	var stack []frame
+	var quote byte
+
+	// valueDone records that a value has been completed in the innermost
+	// object or array
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := &stack[len(stack)-1]
+		switch {
+		case top.open == '{' && top.phase == phaseKey:
+			top.phase = phaseColon
+		default:
+			top.phase = phaseAfter
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if quote != 0 {
+			switch {
+			case c == quote:
+				out.WriteByte('"')
+				quote = 0
+				valueDone()
+			case c == '\\':
+				if i+1 >= len(s) {
+					continue
+				}
+				next := s[i+1]
+				switch {
+				case next == '\'':
+					out.WriteByte('\'')
+					i++
+				case strings.IndexByte(`"\/bfnrtu`, next) >= 0:
+					out.WriteByte('\\')
+					out.WriteByte(next)
+					i++
+				default:
+					// A lone backslash, such as in a regular expression,
+					// is meant literally
+					out.WriteString(`\\`)
+				}
+			case c == '"':
+				out.WriteString(`\"`)
+			case c == '\n':
+				out.WriteString(`\n`)
+			case c == '\r':
+				out.WriteString(`\r`)
+			case c == '\t':
+				out.WriteString(`\t`)
+			case c < 0x20:
+				fmt.Fprintf(&out, `\u%04x`, c)
+			default:
+				out.WriteByte(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '\'':
+			quote = c
+			out.WriteByte('"')
+		case c == '{' || c == '[':
+			valueDone()
+			out.WriteByte(c)
+			stack = append(stack, frame{open: c, start: out.Len()})
+		case c == '}' || c == ']':
+			trimTrailing(&out)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out.WriteByte(c)
+		case c == ':':
+			if len(stack) > 0 {
+				stack[len(stack)-1].phase = phaseValue
+			}
+			out.WriteByte(c)
+		case c == ',':
+			out.WriteByte(c)
+			if len(stack) > 0 {
+				stack[len(stack)-1].phase = phaseKey
+				stack[len(stack)-1].start = out.Len()
+			}
+		case isIdentByte(c):
+			// A bare word is a literal such as true or 12, or an unquoted key
+			j := i
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			word := s[i:j]
+			if len(stack) > 0 && stack[len(stack)-1].open == '{' && stack[len(stack)-1].phase == phaseKey {
+				out.WriteString(`"` + word + `"`)
+			} else {
+				out.WriteString(word)
+			}
+			valueDone()
+			i = j - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	// Close whatever the response left open
+	if quote != 0 {
+		if len(stack) == 0 {
+			out.WriteByte('"')
+		} else {
+			top := &stack[len(stack)-1]
+			truncated := out.String()[:top.start]
+			out.Reset()
+			out.WriteString(truncated)
+			top.phase = phaseKey
+		}
+	}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		trimTrailing(&out)
+		switch top.phase {
+		case phaseColon:
+			out.WriteString(":null")
+		case phaseValue:
+			out.WriteString("null")
+		}
+		if top.open == '{' {
+			out.WriteByte('}')
+		} else {
+			out.WriteByte(']')
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	return out.String()
+}
+
+// trimTrailing removes trailing whitespace and a trailing comma from the output
+func trimTrailing(out *strings.Builder) {
+	trimmed := strings.TrimRight(out.String(), " \t\r\n")
+	trimmed = strings.TrimSuffix(trimmed, ",")
+	if len(trimmed) != out.Len() {
+		out.Reset()
+		out.WriteString(trimmed)
+	}
+}
+
+// isIdentByte reports whether c may be part of a bare JSON literal or an
+// unquoted key
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c == '+' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseCorpus parses real model outputs collected in testdata/responses
+func TestParseCorpus(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{file: "bare_json.txt", want: []string{"ls -la"}},
+		{file: "inline_code_before_block.txt", want: []string{"ls -la"}},
+		{file: "chatty_preamble.txt", want: []string{"find . -type f -mtime -1"}},
+		{file: "trailing_comma.txt", want: []string{"du -sh * | sort -h"}},
+		{file: "single_quotes.txt", want: []string{`grep -rn "TODO" src/`}},
+		{file: "unescaped_newline.txt", want: []string{"for f in *.log; do\n  gzip \"$f\"\ndone"}},
+		{file: "truncated.txt", want: []string{"tar -czf backup.tar.gz ~/projects"}},
+		{file: "truncated_string.txt", wantErr: true},
+		{file: "nested_object.txt", want: []string{"git log --oneline -n 10"}},
+		{file: "braces_in_command.txt", want: []string{`find . -name '*.tmp' -exec rm {} \;`}},
+		{file: "regex_backslash.txt", want: []string{`grep -E '^\d{3}-\d{4}$' phones.txt`}},
+		{file: "unquoted_keys.txt", want: []string{"uname -a"}},
+		{file: "commands_list.txt", want: []string{"ps aux --sort=-%mem | head", "top -o %MEM -n 1", "free -h"}},
+		{file: "curly_prose_first.txt", want: []string{"ls -lh /var/log"}},
+		{file: "rich_fields.txt", want: []string{"find /tmp -name '*.log' -mtime +7 -delete"}},
+		{file: "no_json.txt", wantErr: true},
+		{file: "json_without_command.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSuffix(tt.file, ".txt"), func(t *testing.T) {
+			response, err := os.ReadFile(filepath.Join("testdata", "responses", tt.file))
+			if err != nil {
+				t.Fatalf("Failed to read corpus file: %v", err)
+			}
+
+			responses, err := ParseCommandResponses(string(response))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandResponses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := commandsOf(responses); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ParseCommandResponses() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "valid", input: `{"command": "ls"}`, want: `{"command": "ls"}`},
+		{name: "trailing comma", input: `{"a": [1, 2,], }`, want: `{"a": [1, 2]}`},
+		{name: "single quotes", input: `{'a': 'it\'s "x"'}`, want: `{"a": "it's \"x\""}`},
+		{name: "raw tab", input: "{\"a\": \"x\ty\"}", want: `{"a": "x\ty"}`},
+		{name: "unquoted key", input: `{a: true}`, want: `{"a": true}`},
+		{name: "missing value", input: `{"a": 1, "b":`, want: `{"a": 1, "b":null}`},
+		{name: "dangling key", input: `{"a": 1, "b"`, want: `{"a": 1, "b":null}`},
+		{name: "cut off string", input: `{"a": 1, "b": "par`, want: `{"a": 1}`},
+		{name: "cut off list", input: `{"a": ["x", "y`, want: `{"a": ["x"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repairJSON(tt.input); got != tt.want {
+				t.Errorf("repairJSON(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCommandJSONNoCommand(t *testing.T) {
+	if _, err := ExtractCommandJSON("plain text"); !errors.Is(err, ErrNoCommand) {
+		t.Errorf("ExtractCommandJSON() error = %v, want ErrNoCommand", err)
+	}
+}
@@ -2,100 +2,215 @@ package parser
 
 import (
 	"encoding/json"
-	"regexp"
 	"strings"
 )
 
-// MaxContextTokens is the maximum number of tokens to keep in context
-const MaxContextTokens = 1500
+// Risk levels the LLM reports for a command
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
 
 // CommandResponse represents the parsed command from the LLM response
 type CommandResponse struct {
 	Command string `json:"command"`
-}
 
-// ContextManager manages the context for the LLM
-type ContextManager struct {
-	tokenBuffer []rune
-	maxTokens   int
+	// Explanation is a one line description of what the command does
+	Explanation string `json:"explanation,omitempty"`
+
+	// Risk is the risk level reported by the LLM, one of RiskLow, RiskMedium
+	// or RiskHigh, or empty if it reported none
+	Risk string `json:"risk,omitempty"`
+
+	// Requires lists the binaries the command depends on
+	Requires []string `json:"requires,omitempty"`
+
+	// ModifiesFiles is true if the command creates, changes or deletes files
+	ModifiesFiles bool `json:"modifies_files,omitempty"`
 }
 
-// NewContextManager creates a new context manager
-func NewContextManager() *ContextManager {
-	return &ContextManager{
-		tokenBuffer: make([]rune, 0, MaxContextTokens),
-		maxTokens:   MaxContextTokens,
+// UnmarshalJSON decodes a command response, which may also be given as just
+// the command string
+func (c *CommandResponse) UnmarshalJSON(data []byte) error {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, `"`) {
+		*c = CommandResponse{}
+		return json.Unmarshal(data, &c.Command)
 	}
+
+	// Decode through a type without this method to avoid recursion
+	type commandResponse CommandResponse
+	var resp commandResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	*c = CommandResponse(resp)
+	c.Risk = normalizeRisk(c.Risk)
+	return nil
 }
 
-// AddToken adds a token to the context
-func (cm *ContextManager) AddToken(token rune) {
-	if len(cm.tokenBuffer) >= cm.maxTokens {
-		// Remove the first token
-		cm.tokenBuffer = cm.tokenBuffer[1:]
+// normalizeRisk maps a risk level reported by the LLM to one of the known
+// levels, or to empty if it is not one of them
+func normalizeRisk(risk string) string {
+	switch risk = strings.ToLower(strings.TrimSpace(risk)); risk {
+	case RiskLow, RiskMedium, RiskHigh:
+		return risk
+	default:
+		return ""
 	}
-	cm.tokenBuffer = append(cm.tokenBuffer, token)
 }
 
-// Flush clears the context
-func (cm *ContextManager) Flush() {
-	cm.tokenBuffer = make([]rune, 0, cm.maxTokens)
+// CommandsResponse represents a response holding several alternative commands
+type CommandsResponse struct {
+	Commands []CommandResponse `json:"commands"`
 }
 
-// AddChunk adds a chunk of text to the context
-func (cm *ContextManager) AddChunk(chunk string) {
-	cm.Flush()
-	for _, c := range chunk {
-		cm.AddToken(c)
+// ParseLLMResponse parses the LLM response to extract the command
+func ParseLLMResponse(response string) (string, error) {
+	responses, err := ParseCommandResponses(response)
+	if err != nil {
+		return "", err
 	}
+	return responses[0].Command, nil
 }
 
-// GetContext returns the current context
-func (cm *ContextManager) GetContext() string {
-	if len(cm.tokenBuffer) == 0 {
-		return ""
+// ParseCommandResponses parses a free form LLM response holding either a single
+// command or a list of alternative commands. The JSON is found with
+// ExtractCommandJSON, so it may be wrapped in prose or markdown and slightly
+// malformed.
+func ParseCommandResponses(response string) ([]CommandResponse, error) {
+	jsonContent, err := ExtractCommandJSON(response)
+	if err != nil {
+		return nil, err
 	}
-	return string(cm.tokenBuffer)
+
+	responses, err := DecodeCommandResponses(jsonContent)
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) == 0 {
+		return nil, ErrNoCommand
+	}
+	return responses, nil
 }
 
-// ParseLLMResponse parses the LLM response to extract the command
-func ParseLLMResponse(response string) (string, error) {
-	// Try to extract JSON from markdown code blocks
-	jsonContent := extractJSONFromMarkdown(response)
-	if jsonContent == "" {
-		// If no code blocks found, try to parse the whole response as JSON
-		jsonContent = response
+// DecodeCommandResponses decodes a response that is known to be a bare JSON
+// object, such as one produced with structured output, holding either a single
+// command or a list of alternative commands
+func DecodeCommandResponses(content string) ([]CommandResponse, error) {
+	var commandsResp CommandsResponse
+	err := json.Unmarshal([]byte(content), &commandsResp)
+	if err != nil {
+		return nil, err
 	}
 
-	// Parse the JSON
+	// The object itself holds the command of a single command response
 	var commandResp CommandResponse
-	err := json.Unmarshal([]byte(jsonContent), &commandResp)
-	if err != nil {
-		return "", err
+	if err := json.Unmarshal([]byte(content), &commandResp); err != nil {
+		return nil, err
 	}
 
-	return commandResp.Command, nil
+	responses := make([]CommandResponse, 0, len(commandsResp.Commands)+1)
+	if commandResp.Command != "" {
+		responses = append(responses, commandResp)
+	}
+	for _, resp := range commandsResp.Commands {
+		if resp.Command != "" {
+			responses = append(responses, resp)
+		}
+	}
+	return responses, nil
 }
 
-// extractJSONFromMarkdown extracts JSON content from markdown code blocks
-func extractJSONFromMarkdown(markdown string) string {
-	// Try to find JSON in code blocks
-	codeBlockRegex := regexp.MustCompile("```(?:json)?\\s*\\n([\\s\\S]*?)\\n```")
-	matches := codeBlockRegex.FindAllStringSubmatch(markdown, -1)
+// ParsePartialCommand extracts the command from a response that may still be
+// streaming. It returns false until the value of the "command" key has been
+// received in full.
+func ParsePartialCommand(partial string) (string, bool) {
+	rest, ok := skipToValue(partial, `"command"`)
+	if !ok {
+		return "", false
+	}
 
-	if len(matches) > 0 {
-		// Return the content of the first code block
-		return strings.TrimSpace(matches[0][1])
+	command, _, ok := scanString(rest)
+	return command, ok
+}
+
+// ParsePartialCommands extracts the commands from a response that may still be
+// streaming. The response may hold a single command or a list of commands,
+// given as strings or as objects with a "command" key. Only the commands that
+// have been received in full are returned.
+func ParsePartialCommands(partial string) []string {
+	rest, ok := skipToValue(partial, `"commands"`)
+	if !ok {
+		if command, ok := ParsePartialCommand(partial); ok {
+			return []string{command}
+		}
+		return nil
 	}
 
-	// If no code blocks found, try to find inline code
-	inlineCodeRegex := regexp.MustCompile("`([^`]*)`")
-	inlineMatches := inlineCodeRegex.FindAllStringSubmatch(markdown, -1)
+	if !strings.HasPrefix(rest, "[") {
+		return nil
+	}
+	rest = rest[1:]
+
+	var commands []string
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n,")
+		if strings.HasPrefix(rest, "{") {
+			end, closed := scanObject(rest, 0)
+			if command, ok := ParsePartialCommand(rest[:end]); ok {
+				commands = append(commands, command)
+			}
+			if !closed {
+				return commands
+			}
+			rest = rest[end:]
+			continue
+		}
+
+		command, remaining, ok := scanString(rest)
+		if !ok {
+			return commands
+		}
+		commands = append(commands, command)
+		rest = remaining
+	}
+}
+
+// skipToValue returns the text following the given JSON key and its colon
+func skipToValue(partial, key string) (string, bool) {
+	idx := strings.Index(partial, key)
+	if idx < 0 {
+		return "", false
+	}
+
+	rest := strings.TrimLeft(partial[idx+len(key):], " \t\r\n")
+	if !strings.HasPrefix(rest, ":") {
+		return "", false
+	}
+	return strings.TrimLeft(rest[1:], " \t\r\n"), true
+}
+
+// scanString decodes the JSON string at the start of s, returning false if s
+// does not start with a complete string
+func scanString(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
 
-	if len(inlineMatches) > 0 {
-		// Return the content of the first inline code
-		return strings.TrimSpace(inlineMatches[0][1])
+	// Find the closing quote, skipping escaped characters
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			var value string
+			if err := json.Unmarshal([]byte(s[:i+1]), &value); err != nil {
+				return "", s, false
+			}
+			return value, s[i+1:], true
+		}
 	}
 
-	return ""
+	return "", s, false
 }
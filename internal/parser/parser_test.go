@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -51,31 +52,151 @@ func TestParseLLMResponse(t *testing.T) {
 	}
 }
 
-func TestContextManager(t *testing.T) {
-	cm := NewContextManager()
+func TestParsePartialCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+		want    string
+		wantOk  bool
+	}{
+		{name: "empty", partial: "", wantOk: false},
+		{name: "key only", partial: `{"command"`, wantOk: false},
+		{name: "incomplete value", partial: `{"command": "ls -l`, wantOk: false},
+		{name: "trailing escape", partial: `{"command": "echo \`, wantOk: false},
+		{name: "complete value", partial: `{"command": "ls -la"`, want: "ls -la", wantOk: true},
+		{name: "complete object", partial: `{"command": "ls -la"}`, want: "ls -la", wantOk: true},
+		{name: "escaped quotes", partial: `{"command": "echo \"hi\""`, want: `echo "hi"`, wantOk: true},
+		{name: "code block", partial: "```json\n{\n  \"command\": \"pwd\"", want: "pwd", wantOk: true},
+	}
 
-	// Test empty context
-	if cm.GetContext() != "" {
-		t.Errorf("Expected empty context, got %q", cm.GetContext())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParsePartialCommand(tt.partial)
+			if ok != tt.wantOk {
+				t.Errorf("ParsePartialCommand() ok = %v, want %v", ok, tt.wantOk)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParsePartialCommand() = %v, want %v", got, tt.want)
+			}
+		})
 	}
+}
 
-	// Test adding a chunk
-	cm.AddChunk("test chunk")
-	if cm.GetContext() != "test chunk" {
-		t.Errorf("Expected context to be 'test chunk', got %q", cm.GetContext())
+// commandsOf returns the commands of parsed responses
+func commandsOf(responses []CommandResponse) []string {
+	commands := make([]string, len(responses))
+	for i, resp := range responses {
+		commands[i] = resp.Command
 	}
+	return commands
+}
 
-	// Test flushing
-	cm.Flush()
-	if cm.GetContext() != "" {
-		t.Errorf("Expected empty context after flush, got %q", cm.GetContext())
+func TestParseCommandResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "single command",
+			response: `{"command": "ls -la"}`,
+			want:     []string{"ls -la"},
+		},
+		{
+			name:     "list of commands",
+			response: `{"commands": ["ls -la", "find . -type f", ""]}`,
+			want:     []string{"ls -la", "find . -type f"},
+		},
+		{
+			name:     "list in code block",
+			response: "```json\n{\"commands\": [\"du -sh *\", \"du -h -d1\"]}\n```",
+			want:     []string{"du -sh *", "du -h -d1"},
+		},
+		{
+			name:     "invalid JSON",
+			response: "not a json",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses, err := ParseCommandResponses(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCommandResponses() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got := commandsOf(responses); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ParseCommandResponses() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePartialCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+		want    []string
+	}{
+		{name: "empty", partial: "", want: nil},
+		{name: "single command", partial: `{"command": "ls"`, want: []string{"ls"}},
+		{name: "list not started", partial: `{"commands": `, want: nil},
+		{name: "first incomplete", partial: `{"commands": ["ls -l`, want: nil},
+		{name: "second incomplete", partial: `{"commands": ["ls -l", "fi`, want: []string{"ls -l"}},
+		{name: "complete list", partial: `{"commands": ["ls -l", "find ."]}`, want: []string{"ls -l", "find ."}},
+		{name: "object command incomplete", partial: `{"commands": [{"command": "ls -l`, want: nil},
+		{name: "object fields streaming", partial: `{"commands": [{"command": "ls -l", "explanation": "Lists fi`, want: []string{"ls -l"}},
+		{name: "second object", partial: `{"commands": [{"command": "ls -l", "risk": "low"}, {"explanation": "x", "command": "find ."`, want: []string{"ls -l", "find ."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParsePartialCommands(tt.partial)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ParsePartialCommands() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCommandResponses(t *testing.T) {
+	content := `{"commands": [
+		{"command": "rm -rf build", "explanation": "Deletes the build directory", "risk": "HIGH", "requires": ["rm"], "modifies_files": true},
+		{"command": "ls build", "risk": "unsure"},
+		"make clean"
+	]}`
+
+	got, err := DecodeCommandResponses(content)
+	if err != nil {
+		t.Fatalf("DecodeCommandResponses() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("DecodeCommandResponses() returned %d commands, want 3", len(got))
+	}
+
+	first := got[0]
+	if first.Command != "rm -rf build" || first.Explanation != "Deletes the build directory" {
+		t.Errorf("DecodeCommandResponses()[0] = %+v, want the command and its explanation", first)
+	}
+	if first.Risk != RiskHigh || !first.ModifiesFiles || len(first.Requires) != 1 || first.Requires[0] != "rm" {
+		t.Errorf("DecodeCommandResponses()[0] = %+v, want high risk, modifying files and requiring rm", first)
+	}
+	if got[1].Risk != "" {
+		t.Errorf("DecodeCommandResponses()[1].Risk = %q, want unknown levels to be dropped", got[1].Risk)
+	}
+	if got[2].Command != "make clean" {
+		t.Errorf("DecodeCommandResponses()[2].Command = %q, want %q", got[2].Command, "make clean")
 	}
 
-	// Test adding tokens
-	for _, c := range "hello" {
-		cm.AddToken(c)
+	// A single command response holds the fields at the top level
+	got, err = DecodeCommandResponses(`{"command": "df -h", "explanation": "Shows disk usage", "risk": "low", "requires": ["df"], "modifies_files": false}`)
+	if err != nil {
+		t.Fatalf("DecodeCommandResponses() error = %v", err)
 	}
-	if cm.GetContext() != "hello" {
-		t.Errorf("Expected context to be 'hello', got %q", cm.GetContext())
+	if len(got) != 1 || got[0].Explanation != "Shows disk usage" || got[0].Risk != RiskLow {
+		t.Errorf("DecodeCommandResponses() = %+v, want df -h with its explanation and low risk", got)
 	}
 }
@@ -0,0 +1,297 @@
+// Package platform probes the system shell commands will run on, so the LLM
+// can suggest commands that work with its distribution, userland and tools.
+package platform
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/cache"
+)
+
+// cacheTTL is how long a probe result is reused before the system is probed
+// again
+const cacheTTL = 24 * time.Hour
+
+// probeTimeout is how long a single tool may take to report its version
+const probeTimeout = 2 * time.Second
+
+// Userlands, the flavor of the core utilities such as ls, sed and find
+const (
+	UserlandGNU     = "GNU"
+	UserlandBusyBox = "BusyBox"
+	UserlandBSD     = "BSD"
+)
+
+// packageManagers are the package managers looked for on $PATH
+var packageManagers = []string{
+	"apt", "dnf", "yum", "pacman", "zypper", "apk", "emerge", "xbps-install",
+	"nix", "brew", "port", "pkg", "snap", "flatpak", "winget", "choco", "scoop",
+}
+
+// tools are the tools whose version is reported, with the arguments that
+// print it
+var tools = []struct {
+	name string
+	args []string
+}{
+	{name: "git", args: []string{"--version"}},
+	{name: "python3", args: []string{"--version"}},
+	{name: "node", args: []string{"--version"}},
+	{name: "go", args: []string{"version"}},
+	{name: "docker", args: []string{"--version"}},
+	{name: "kubectl", args: []string{"version", "--client"}},
+	{name: "jq", args: []string{"--version"}},
+	{name: "rg", args: []string{"--version"}},
+	{name: "fd", args: []string{"--version"}},
+	{name: "awk", args: []string{"--version"}},
+	{name: "sed", args: []string{"--version"}},
+}
+
+// Info describes the system commands will run on
+type Info struct {
+	// OS is the operating system, such as "Linux" or "macOS"
+	OS string `json:"os"`
+
+	// Distro is the distribution and its version, such as "Ubuntu 22.04.3 LTS"
+	Distro string `json:"distro,omitempty"`
+
+	Arch string `json:"arch"`
+
+	// Userland is one of UserlandGNU, UserlandBusyBox or UserlandBSD, or empty
+	// if it is not known
+	Userland        string `json:"userland,omitempty"`
+	UserlandVersion string `json:"userland_version,omitempty"`
+
+	// PackageManagers are the package managers found on $PATH
+	PackageManagers []string `json:"package_managers,omitempty"`
+
+	// Tools are the installed tools of the ones probed for
+	Tools []Tool `json:"tools,omitempty"`
+
+	ProbedAt time.Time `json:"probed_at"`
+}
+
+// Tool is an installed tool and its version
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+var (
+	probed    *Info
+	probeOnce sync.Once
+)
+
+// Probe returns the description of the current system. It is probed once a day
+// and cached in the user's cache directory in between.
+func Probe() *Info {
+	probeOnce.Do(func() {
+		path := cache.Path("platform.json")
+		if info, err := loadCache(path, time.Now()); err == nil {
+			probed = info
+			return
+		}
+
+		probed = probe(runCommand)
+		if path != "" {
+			// A failure to cache only means probing again next time
+			_ = cache.Save(path, probed)
+		}
+	})
+	return probed
+}
+
+// runner runs a command and returns its output
+type runner func(ctx context.Context, name string, args ...string) (string, error)
+
+// runCommand runs a command and returns its combined output
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	return string(out), err
+}
+
+// probe describes the current system using run to ask tools for their version
+func probe(run runner) *Info {
+	info := &Info{
+		OS:       osName(runtime.GOOS),
+		Arch:     runtime.GOARCH,
+		ProbedAt: time.Now(),
+	}
+
+	if f, err := os.Open("/etc/os-release"); err == nil {
+		info.Distro = distroName(parseOSRelease(f))
+		f.Close()
+	} else if runtime.GOOS == "darwin" {
+		if version, err := run(context.Background(), "sw_vers", "-productVersion"); err == nil {
+			info.Distro = "macOS " + strings.TrimSpace(version)
+		}
+	}
+
+	info.Userland, info.UserlandVersion = detectUserland(run, runtime.GOOS)
+
+	for _, name := range packageManagers {
+		if _, err := exec.LookPath(name); err == nil {
+			info.PackageManagers = append(info.PackageManagers, name)
+		}
+	}
+
+	// Ask the installed tools for their version in parallel
+	var wg sync.WaitGroup
+	found := make([]*Tool, len(tools))
+	for i, tool := range tools {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string, args []string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+			out, _ := run(ctx, name, args...)
+			found[i] = &Tool{Name: name, Version: parseVersion(out)}
+		}(i, tool.name, tool.args)
+	}
+	wg.Wait()
+	for _, tool := range found {
+		if tool != nil {
+			info.Tools = append(info.Tools, *tool)
+		}
+	}
+
+	return info
+}
+
+// osName returns the name of an operating system as known to runtime.GOOS
+func osName(goos string) string {
+	switch goos {
+	case "darwin":
+		return "macOS"
+	case "linux":
+		return "Linux"
+	case "windows":
+		return "Windows"
+	case "freebsd", "openbsd", "netbsd", "dragonfly":
+		return strings.Replace(goos, "bsd", "BSD", 1)
+	default:
+		return goos
+	}
+}
+
+// parseOSRelease parses the KEY=value lines of /etc/os-release
+func parseOSRelease(r io.Reader) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[key] = strings.Trim(value, `"'`)
+	}
+	return fields
+}
+
+// distroName returns the name of the distribution described by os-release
+func distroName(fields map[string]string) string {
+	if name := fields["PRETTY_NAME"]; name != "" {
+		return name
+	}
+	return strings.TrimSpace(fields["NAME"] + " " + fields["VERSION_ID"])
+}
+
+// detectUserland finds out whether the core utilities are GNU, BusyBox or BSD
+func detectUserland(run runner, goos string) (string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	// BSD ls rejects --version, BusyBox mentions itself in the error
+	out, err := run(ctx, "ls", "--version")
+	switch {
+	case strings.Contains(out, "GNU coreutils"):
+		return UserlandGNU, parseVersion(out)
+	case strings.Contains(out, "BusyBox"):
+		return UserlandBusyBox, parseVersion(out)
+	case err != nil && goos != "linux" && goos != "windows":
+		return UserlandBSD, ""
+	}
+
+	if out, _ := run(ctx, "busybox"); strings.Contains(out, "BusyBox") {
+		return UserlandBusyBox, parseVersion(out)
+	}
+	return "", ""
+}
+
+// versionPattern matches a version number such as 2.39.2 or v18.17.1
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// parseVersion returns the first version number in the first line of output
+// that has one
+func parseVersion(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if version := versionPattern.FindString(line); version != "" {
+			return version
+		}
+	}
+	return ""
+}
+
+// Summary returns a compact description of the system for the system prompt
+func (i *Info) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The system the shell command will be executed on is %s", i.OS)
+	if i.Distro != "" {
+		fmt.Fprintf(&b, " (%s, %s)", i.Distro, i.Arch)
+	} else {
+		fmt.Fprintf(&b, " (%s)", i.Arch)
+	}
+	b.WriteString(".")
+
+	switch i.Userland {
+	case UserlandGNU:
+		fmt.Fprintf(&b, " Core utilities are %s.", strings.TrimSpace("GNU coreutils "+i.UserlandVersion))
+	case UserlandBusyBox:
+		fmt.Fprintf(&b, " Core utilities are %s, so only their basic options are available.", strings.TrimSpace("BusyBox "+i.UserlandVersion))
+	case UserlandBSD:
+		b.WriteString(" Core utilities are BSD, not GNU.")
+	}
+
+	if len(i.PackageManagers) > 0 {
+		fmt.Fprintf(&b, " Package managers: %s.", strings.Join(i.PackageManagers, ", "))
+	}
+
+	if len(i.Tools) > 0 {
+		tools := make([]string, len(i.Tools))
+		for j, tool := range i.Tools {
+			tools[j] = strings.TrimSpace(tool.Name + " " + tool.Version)
+		}
+		fmt.Fprintf(&b, " Installed tools: %s.", strings.Join(tools, ", "))
+	}
+
+	return b.String()
+}
+
+// loadCache loads a probe result that is not older than cacheTTL
+func loadCache(path string, now time.Time) (*Info, error) {
+	var info Info
+	if err := cache.Load(path, &info); err != nil {
+		return nil, err
+	}
+	if now.Sub(info.ProbedAt) > cacheTTL || info.OS != osName(runtime.GOOS) {
+		return nil, fmt.Errorf("cached platform info from %s is stale", info.ProbedAt.Format(time.RFC3339))
+	}
+	return &info, nil
+}
@@ -0,0 +1,162 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/cache"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		want      string
+	}{
+		{
+			name:      "pretty name",
+			osRelease: "NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\n",
+			want:      "Ubuntu 22.04.3 LTS",
+		},
+		{
+			name:      "name and version",
+			osRelease: "# comment\nNAME='Alpine Linux'\nVERSION_ID=3.19.1\n",
+			want:      "Alpine Linux 3.19.1",
+		},
+		{name: "empty", osRelease: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distroName(parseOSRelease(strings.NewReader(tt.osRelease))); got != tt.want {
+				t.Errorf("distroName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectUserland(t *testing.T) {
+	tests := []struct {
+		name        string
+		goos        string
+		outputs     map[string]string
+		wantName    string
+		wantVersion string
+	}{
+		{
+			name:        "gnu",
+			goos:        "linux",
+			outputs:     map[string]string{"ls": "ls (GNU coreutils) 9.1\nCopyright (C) 2022 Free Software Foundation, Inc.\n"},
+			wantName:    UserlandGNU,
+			wantVersion: "9.1",
+		},
+		{
+			name:        "busybox ls",
+			goos:        "linux",
+			outputs:     map[string]string{"ls": "ls: unrecognized option: version\nBusyBox v1.36.1 (2023-06-02 00:42:02 UTC) multi-call binary.\n"},
+			wantName:    UserlandBusyBox,
+			wantVersion: "1.36.1",
+		},
+		{
+			name:     "bsd",
+			goos:     "darwin",
+			outputs:  map[string]string{"ls": "ls: unrecognized option `--version'\nusage: ls [-@ABCFGHILOPRSTUWabcdefghiklmnopqrstuvwxy1%,] [--color=when] [-D format] [file ...]\n"},
+			wantName: UserlandBSD,
+		},
+		{
+			name: "unknown",
+			goos: "linux",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func(ctx context.Context, name string, args ...string) (string, error) {
+				out, ok := tt.outputs[name]
+				if !ok || !strings.Contains(out, "GNU") {
+					return out, errors.New("exit status 1")
+				}
+				return out, nil
+			}
+
+			name, version := detectUserland(run, tt.goos)
+			if name != tt.wantName || version != tt.wantVersion {
+				t.Errorf("detectUserland() = %q, %q, want %q, %q", name, version, tt.wantName, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]string{
+		"git version 2.39.2\n":                           "2.39.2",
+		"Python 3.11.2\n":                                "3.11.2",
+		"v18.17.1\n":                                     "18.17.1",
+		"go version go1.22.5 linux/amd64\n":              "1.22.5",
+		"Docker version 24.0.5, build ced0996\n":         "24.0.5",
+		"jq-1.6\n":                                       "1.6",
+		"Client Version: v1.28.2\nKustomize Version: v5": "1.28.2",
+		"usage: sed script [-Ealnru]\n":                  "",
+	}
+
+	for out, want := range tests {
+		if got := parseVersion(out); got != want {
+			t.Errorf("parseVersion(%q) = %q, want %q", out, got, want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	info := &Info{
+		OS:              "Linux",
+		Distro:          "Alpine Linux v3.19",
+		Arch:            "arm64",
+		Userland:        UserlandBusyBox,
+		UserlandVersion: "1.36.1",
+		PackageManagers: []string{"apk"},
+		Tools:           []Tool{{Name: "git", Version: "2.43.0"}, {Name: "jq"}},
+	}
+
+	want := "The system the shell command will be executed on is Linux (Alpine Linux v3.19, arm64). Core utilities are BusyBox 1.36.1, so only their basic options are available. Package managers: apk. Installed tools: git 2.43.0, jq."
+	if got := info.Summary(); got != want {
+		t.Errorf("Summary() =\n%s\nwant\n%s", got, want)
+	}
+
+	minimal := &Info{OS: "macOS", Arch: "arm64", Userland: UserlandBSD}
+	want = "The system the shell command will be executed on is macOS (arm64). Core utilities are BSD, not GNU."
+	if got := minimal.Summary(); got != want {
+		t.Errorf("Summary() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shell-ai", "platform.json")
+	now := time.Now()
+
+	if _, err := loadCache(path, now); err == nil {
+		t.Fatal("loadCache() error = nil, want an error for a missing cache")
+	}
+
+	info := probe(func(ctx context.Context, name string, args ...string) (string, error) {
+		return "", errors.New("not found")
+	})
+	if err := cache.Save(path, info); err != nil {
+		t.Fatalf("cache.Save() error = %v", err)
+	}
+
+	cached, err := loadCache(path, now)
+	if err != nil {
+		t.Fatalf("loadCache() error = %v", err)
+	}
+	if cached.Summary() != info.Summary() {
+		t.Errorf("loadCache() = %q, want %q", cached.Summary(), info.Summary())
+	}
+
+	if _, err := loadCache(path, now.Add(cacheTTL+time.Minute)); err == nil {
+		t.Error("loadCache() error = nil, want an error for a stale cache")
+	}
+}
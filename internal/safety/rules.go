@@ -0,0 +1,670 @@
+package safety
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// wrappers are commands that run the command given as their arguments
+var wrappers = map[string]bool{
+	"command": true, "builtin": true, "exec": true, "nohup": true, "time": true,
+	"nice": true, "ionice": true, "xargs": true, "env": true, "timeout": true,
+	"stdbuf": true, "setsid": true,
+}
+
+// privilegeCommands run their arguments as another user, usually root
+var privilegeCommands = map[string]bool{
+	"sudo": true, "doas": true, "pkexec": true, "su": true, "run0": true,
+}
+
+// downloaders fetch content from the network
+var downloaders = map[string]bool{
+	"curl": true, "wget": true, "fetch": true,
+}
+
+// interpreters run code read from their input
+var interpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true,
+}
+
+// shells are the interpreters whose -c argument is a script the rules can
+// parse
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true,
+}
+
+// networkCommands send data over the network
+var networkCommands = map[string]bool{
+	"curl": true, "wget": true, "nc": true, "ncat": true, "netcat": true,
+	"socat": true, "scp": true, "sftp": true, "rsync": true, "ftp": true,
+	"ssh": true, "telnet": true,
+}
+
+// diskCommands format, repartition or wipe disks
+var diskCommands = map[string]bool{
+	"mkfs": true, "mke2fs": true, "mkswap": true, "wipefs": true, "fdisk": true,
+	"sfdisk": true, "cfdisk": true, "parted": true, "gdisk": true, "sgdisk": true,
+}
+
+// criticalPaths are directories whose recursive removal or permission change
+// breaks the system or destroys the user's data
+var criticalPaths = map[string]bool{
+	"/": true, "~": true, "$HOME": true, "/bin": true, "/boot": true, "/dev": true,
+	"/etc": true, "/home": true, "/lib": true, "/lib64": true, "/opt": true,
+	"/proc": true, "/root": true, "/sbin": true, "/srv": true, "/sys": true,
+	"/usr": true, "/var": true, "/System": true, "/Users": true, "/Library": true,
+	"/Applications": true,
+}
+
+// sensitivePaths hold credentials that should never leave the machine
+var sensitivePaths = []string{
+	".ssh/", "/etc/shadow", "/etc/sudoers", ".aws/credentials", ".gnupg",
+	".netrc", ".kube/config", ".docker/config.json", ".git-credentials",
+	".config/gh/hosts.yml",
+}
+
+// checkFile applies the rules to every command of a parsed command line
+func checkFile(a *Analysis, file *syntax.File) {
+	sensitive, network := false, false
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			a.commands = append(a.commands, printCommand(node))
+			args := unwrap(a, node.Args)
+			if len(args) == 0 {
+				return true
+			}
+			if len(args) < len(node.Args) {
+				a.commands = append(a.commands, printCommand(&syntax.CallExpr{Args: args}))
+			}
+			name := commandName(args[0])
+			checkCall(a, name, args)
+			if networkCommands[name] {
+				network = true
+			}
+			for _, arg := range args {
+				if isSensitive(wordText(arg)) {
+					sensitive = true
+				}
+			}
+		case *syntax.BinaryCmd:
+			if node.Op == syntax.Pipe || node.Op == syntax.PipeAll {
+				checkPipe(a, node)
+			}
+		case *syntax.Redirect:
+			if node.Word == nil {
+				return true
+			}
+			target := wordText(node.Word)
+			if isSensitive(target) {
+				sensitive = true
+			}
+			checkRedirect(a, node.Op, target)
+		case *syntax.FuncDecl:
+			if callsFunction(node.Body, node.Name.Value) {
+				a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("defines %s as a function that calls itself, a fork bomb", node.Name.Value))
+			}
+		}
+		return true
+	})
+
+	if sensitive && network {
+		a.add(CategoryNetwork, LevelHigh, "sends credentials or keys over the network")
+	}
+}
+
+// unwrap strips privilege commands and wrappers such as nohup from the start
+// of a command, returning the command they run
+func unwrap(a *Analysis, args []*syntax.Word) []*syntax.Word {
+	for len(args) > 0 {
+		name := commandName(args[0])
+		if privilegeCommands[name] {
+			a.add(CategoryPrivileged, LevelMedium, fmt.Sprintf("runs as root with %s", name))
+			if name == "su" {
+				// su runs its -c argument through a shell rather than running
+				// its arguments
+				if script, ok := suScript(args[1:]); ok {
+					checkScript(a, script)
+				}
+				return nil
+			}
+		} else if !wrappers[name] {
+			return args
+		}
+		args = skipWrapperArgs(name, args[1:])
+	}
+	return args
+}
+
+// valueOptions are the options of each wrapper and privilege command that take
+// a value, such as sudo -u user or nice -n 10, so the value is not taken for
+// the command they run
+var valueOptions = map[string]map[string]bool{
+	"sudo": {
+		"-u": true, "-g": true, "-C": true, "-p": true, "-U": true, "-h": true,
+		"-r": true, "-t": true, "-D": true, "-R": true, "--user": true,
+		"--group": true, "--close-from": true, "--prompt": true, "--other-user": true,
+		"--host": true, "--role": true, "--type": true, "--chdir": true, "--chroot": true,
+	},
+	"doas":    {"-u": true, "-C": true},
+	"pkexec":  {"--user": true},
+	"run0":    {"-u": true, "-g": true, "-D": true, "--user": true, "--group": true, "--chdir": true},
+	"timeout": {"-s": true, "-k": true, "--signal": true, "--kill-after": true},
+	"nice":    {"-n": true, "--adjustment": true},
+	"ionice":  {"-c": true, "-n": true, "-p": true, "-P": true, "-u": true, "--class": true, "--classdata": true},
+	"env":     {"-u": true, "-C": true, "-S": true, "--unset": true, "--chdir": true, "--split-string": true},
+	"xargs": {
+		"-I": true, "-n": true, "-P": true, "-d": true, "-L": true, "-E": true,
+		"-s": true, "-a": true, "--max-args": true, "--max-procs": true,
+		"--delimiter": true, "--max-lines": true, "--arg-file": true,
+	},
+	"stdbuf": {"-i": true, "-o": true, "-e": true},
+}
+
+// skipWrapperArgs skips the options of a wrapper and, for env and timeout, the
+// assignments and duration that come before the command
+func skipWrapperArgs(name string, args []*syntax.Word) []*syntax.Word {
+	for len(args) > 0 {
+		text := wordText(args[0])
+		switch {
+		case text == "--":
+			return args[1:]
+		case valueOptions[name][text]:
+			args = args[min(2, len(args)):]
+		case strings.HasPrefix(text, "-"):
+			args = args[1:]
+		case name == "env" && strings.Contains(text, "="):
+			args = args[1:]
+		case name == "timeout" && text != "" && text[0] >= '0' && text[0] <= '9':
+			args = args[1:]
+		default:
+			return args
+		}
+	}
+	return args
+}
+
+// checkCall applies the rules for a single command
+func checkCall(a *Analysis, name string, args []*syntax.Word) {
+	texts := make([]string, len(args))
+	for i, arg := range args {
+		texts[i] = wordText(arg)
+	}
+	flags, operands := splitArgs(texts[1:])
+
+	switch {
+	case name == "rm":
+		recursive := hasShortFlag(flags, 'r') || hasShortFlag(flags, 'R') || hasFlag(flags, "--recursive")
+		switch {
+		case hasFlag(flags, "--no-preserve-root"):
+			a.add(CategoryDestructive, LevelHigh, "deletes files with --no-preserve-root")
+		case recursive && anyCritical(operands):
+			a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("recursively deletes %s", firstCritical(operands)))
+		case recursive:
+			a.add(CategoryDestructive, LevelMedium, "recursively deletes files")
+		default:
+			a.add(CategoryDestructive, LevelLow, "deletes files")
+		}
+
+	case name == "dd":
+		for _, operand := range operands {
+			if device, ok := strings.CutPrefix(operand, "of="); ok && strings.HasPrefix(device, "/dev/") && isDiskDevice(device) {
+				a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("writes directly to the device %s", device))
+			}
+		}
+
+	case diskCommands[name] || strings.HasPrefix(name, "mkfs."):
+		a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("formats or repartitions a disk with %s", name))
+
+	case name == "shred":
+		a.add(CategoryDestructive, LevelMedium, "irrecoverably overwrites files")
+		for _, operand := range operands {
+			if strings.HasPrefix(operand, "/dev/") {
+				a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("overwrites the device %s", operand))
+			}
+		}
+
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		recursive := hasShortFlag(flags, 'R') || hasFlag(flags, "--recursive")
+		if recursive && anyCritical(operands[min(1, len(operands)):]) {
+			a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("recursively changes the ownership or permissions of %s", firstCritical(operands[1:])))
+		}
+		if name == "chmod" && len(operands) > 0 && worldWritable(operands[0]) {
+			a.add(CategoryDestructive, LevelMedium, "makes files writable by every user")
+		}
+
+	case name == "mv":
+		if len(operands) > 1 && anyCritical(operands[:len(operands)-1]) {
+			a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("moves %s", firstCritical(operands[:len(operands)-1])))
+		}
+		if len(operands) > 0 && operands[len(operands)-1] == "/dev/null" {
+			a.add(CategoryDestructive, LevelMedium, "moves files to /dev/null, which discards them")
+		}
+
+	case name == "find":
+		if hasFlag(texts, "-delete") || execsRemove(texts) {
+			if len(operands) > 0 && criticalPath(operands[0]) {
+				a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("deletes the files found in %s", operands[0]))
+			} else {
+				a.add(CategoryDestructive, LevelMedium, "deletes the files it finds")
+			}
+		}
+
+	case name == "shutdown" || name == "reboot" || name == "halt" || name == "poweroff":
+		a.add(CategoryDestructive, LevelMedium, "shuts down or reboots the machine")
+
+	case (name == "init" || name == "telinit") && len(operands) > 0 && (operands[0] == "0" || operands[0] == "6"):
+		a.add(CategoryDestructive, LevelMedium, "shuts down or reboots the machine")
+
+	case name == "kill" && slices.Contains(killTargets(texts[1:]), "-1"):
+		a.add(CategoryDestructive, LevelHigh, "kills every process the user may signal")
+
+	case name == "killall5":
+		a.add(CategoryDestructive, LevelHigh, "kills every process")
+
+	case name == "crontab" && hasShortFlag(flags, 'r'):
+		a.add(CategoryDestructive, LevelMedium, "removes all cron jobs of the user")
+
+	case name == "git" && len(operands) > 0:
+		switch operands[0] {
+		case "push":
+			if hasFlag(flags, "--force") || hasShortFlag(flags, 'f') || hasFlag(flags, "--force-with-lease") {
+				a.add(CategoryDestructive, LevelMedium, "force pushes, which can overwrite remote history")
+			}
+		case "reset":
+			if hasFlag(flags, "--hard") {
+				a.add(CategoryDestructive, LevelMedium, "discards uncommitted changes")
+			}
+		case "clean":
+			if hasShortFlag(flags, 'f') {
+				a.add(CategoryDestructive, LevelMedium, "deletes untracked files")
+			}
+		}
+
+	case name == "curl":
+		for i, flag := range texts {
+			next := ""
+			if i+1 < len(texts) {
+				next = texts[i+1]
+			}
+			switch {
+			case flag == "-T" || flag == "--upload-file":
+				a.add(CategoryNetwork, LevelMedium, fmt.Sprintf("uploads %s", next))
+			case (flag == "-d" || flag == "-F" || strings.HasPrefix(flag, "--data") || flag == "--form") && strings.Contains(next, "@"):
+				a.add(CategoryNetwork, LevelMedium, "uploads the contents of a local file")
+			}
+		}
+
+	case name == "wget":
+		for _, flag := range flags {
+			if strings.HasPrefix(flag, "--post-file") || strings.HasPrefix(flag, "--body-file") {
+				a.add(CategoryNetwork, LevelMedium, "uploads the contents of a local file")
+			}
+		}
+
+	case name == "nc" || name == "ncat" || name == "netcat":
+		if hasShortFlag(flags, 'e') || hasShortFlag(flags, 'c') || hasFlag(flags, "--exec") || hasFlag(flags, "--sh-exec") {
+			a.add(CategoryNetwork, LevelHigh, "exposes a shell over the network")
+		} else {
+			a.add(CategoryNetwork, LevelLow, "opens a raw network connection")
+		}
+
+	case name == "socat":
+		for _, operand := range operands {
+			lower := strings.ToLower(operand)
+			if strings.HasPrefix(lower, "exec:") || strings.HasPrefix(lower, "system:") {
+				a.add(CategoryNetwork, LevelHigh, "exposes a shell over the network")
+			}
+		}
+
+	case name == "eval" || interpreters[name]:
+		for _, arg := range args[1:] {
+			if runsDownloader(arg) {
+				a.add(CategoryNetwork, LevelHigh, "runs code downloaded from the internet")
+			}
+		}
+		if name == "eval" {
+			checkScript(a, strings.Join(texts[1:], " "))
+		} else if script, ok := shellScript(texts[1:]); ok && shells[name] {
+			checkScript(a, script)
+		}
+	}
+}
+
+// checkScript applies the rules to a script another command runs, such as the
+// argument of sh -c. A script that cannot be parsed is high risk, as it runs
+// without the rules vouching for it.
+func checkScript(a *Analysis, script string) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		a.add(CategoryUnparsed, LevelHigh, "runs a script that could not be parsed, review it carefully")
+		return
+	}
+	checkFile(a, file)
+}
+
+// shellScript returns the script a shell runs with -c, which is its first
+// operand once -c is given, as in bash -o pipefail -lc 'script'
+func shellScript(args []string) (string, bool) {
+	command := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-o" || arg == "+o" || arg == "-O" || arg == "+O":
+			// The option name is the next argument
+			i++
+		case arg == "--":
+			if command && i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", false
+		case strings.HasPrefix(arg, "--") || strings.HasPrefix(arg, "+"):
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if strings.IndexByte(arg[1:], 'c') >= 0 {
+				command = true
+			}
+		default:
+			// Without -c the first operand is a script file
+			return arg, command
+		}
+	}
+	return "", false
+}
+
+// suScript returns the command su runs with -c or --command
+func suScript(args []*syntax.Word) (string, bool) {
+	for i, arg := range args {
+		text := wordText(arg)
+		switch {
+		case (text == "-c" || text == "--command") && i+1 < len(args):
+			return wordText(args[i+1]), true
+		case strings.HasPrefix(text, "--command="):
+			return strings.TrimPrefix(text, "--command="), true
+		}
+	}
+	return "", false
+}
+
+// checkPipe flags downloads piped straight into an interpreter
+func checkPipe(a *Analysis, pipe *syntax.BinaryCmd) {
+	if !stmtCalls(pipe.X, downloaders) {
+		return
+	}
+
+	call, ok := pipe.Y.Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return
+	}
+	args := unwrap(&Analysis{}, call.Args)
+	if len(args) > 0 && interpreters[commandName(args[0])] {
+		a.add(CategoryNetwork, LevelHigh, fmt.Sprintf("pipes a download into %s, running code from the internet", commandName(args[0])))
+	}
+}
+
+// checkRedirect flags output redirected over devices and system files
+func checkRedirect(a *Analysis, op syntax.RedirOperator, target string) {
+	switch op {
+	case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+	default:
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(target, "/dev/") && isDiskDevice(target):
+		a.add(CategoryDestructive, LevelHigh, fmt.Sprintf("overwrites the device %s", target))
+	case strings.HasPrefix(target, "/etc/") || strings.HasPrefix(target, "/boot/"):
+		a.add(CategoryDestructive, LevelMedium, fmt.Sprintf("overwrites the system file %s", target))
+	}
+}
+
+// isDiskDevice reports whether the device path names a disk rather than a
+// pseudo device such as /dev/null
+func isDiskDevice(device string) bool {
+	name := strings.TrimPrefix(device, "/dev/")
+	for _, prefix := range []string{"sd", "hd", "vd", "xvd", "nvme", "mmcblk", "disk", "rdisk", "md", "dm-", "mapper/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stmtCalls reports whether the statement runs any of the named commands
+func stmtCalls(stmt *syntax.Stmt, names map[string]bool) bool {
+	found := false
+	syntax.Walk(stmt, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			args := unwrap(&Analysis{}, call.Args)
+			if len(args) > 0 && names[commandName(args[0])] {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// runsDownloader reports whether a word holds a command or process
+// substitution that downloads something, as in bash <(curl ...)
+func runsDownloader(word *syntax.Word) bool {
+	found := false
+	syntax.Walk(word, func(node syntax.Node) bool {
+		var stmts []*syntax.Stmt
+		switch node := node.(type) {
+		case *syntax.CmdSubst:
+			stmts = node.Stmts
+		case *syntax.ProcSubst:
+			stmts = node.Stmts
+		}
+		for _, stmt := range stmts {
+			if stmtCalls(stmt, downloaders) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// callsFunction reports whether the statement calls the named function
+func callsFunction(body *syntax.Stmt, name string) bool {
+	found := false
+	syntax.Walk(body, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 && call.Args[0].Lit() == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// printCommand returns the text of a simple command as it would be written
+func printCommand(call *syntax.CallExpr) string {
+	var text strings.Builder
+	if err := syntax.NewPrinter().Print(&text, call); err != nil {
+		return ""
+	}
+	return text.String()
+}
+
+// commandName returns the base name of the command a word runs
+func commandName(word *syntax.Word) string {
+	return path.Base(wordText(word))
+}
+
+// wordText returns the text of a word with quotes and escapes removed, so \rm
+// is rm. Parameter expansions are kept as written, command substitutions are
+// elided.
+func wordText(word *syntax.Word) string {
+	var text strings.Builder
+	writeParts(&text, word.Parts, false)
+	return text.String()
+}
+
+// writeParts writes the text of word parts, quoted if they are inside double
+// quotes
+func writeParts(text *strings.Builder, parts []syntax.WordPart, quoted bool) {
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			text.WriteString(unescape(part.Value, quoted))
+		case *syntax.SglQuoted:
+			text.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			writeParts(text, part.Parts, true)
+		case *syntax.ParamExp:
+			if part.Param != nil {
+				text.WriteString("$" + part.Param.Value)
+			}
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			text.WriteString("$(...)")
+		}
+	}
+}
+
+// unescape removes the backslashes of a literal. Outside quotes a backslash
+// escapes any character, inside double quotes only $, `, ", \ and a newline.
+func unescape(lit string, quoted bool) string {
+	if !strings.Contains(lit, `\`) {
+		return lit
+	}
+
+	var text strings.Builder
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '\\' || i+1 == len(lit) {
+			text.WriteByte(lit[i])
+			continue
+		}
+		next := lit[i+1]
+		if quoted && !strings.ContainsRune("$`\"\\\n", rune(next)) {
+			text.WriteByte(lit[i])
+			continue
+		}
+		i++
+		if next != '\n' {
+			text.WriteByte(next)
+		}
+	}
+	return text.String()
+}
+
+// splitArgs separates the flags of a command from its operands
+func splitArgs(args []string) (flags, operands []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, append(operands, args[i+1:]...)
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			flags = append(flags, arg)
+		} else {
+			operands = append(operands, arg)
+		}
+	}
+	return flags, operands
+}
+
+// hasShortFlag reports whether a short flag is given, alone or combined with
+// others as in -rf
+func hasShortFlag(flags []string, flag byte) bool {
+	for _, f := range flags {
+		if len(f) > 1 && f[0] == '-' && f[1] != '-' && strings.IndexByte(f[1:], flag) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlag reports whether a long flag or exact argument is given
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag || strings.HasPrefix(f, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// killTargets returns the processes a kill command signals. The first
+// argument names the signal if it starts with a dash, as in kill -9 or
+// kill -s KILL, so kill -1 123 sends signal 1 while kill -9 -1 signals every
+// process.
+func killTargets(args []string) []string {
+	if len(args) > 0 && (args[0] == "-s" || args[0] == "-n") {
+		args = args[min(2, len(args)):]
+	} else if len(args) > 1 && strings.HasPrefix(args[0], "-") && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	return args
+}
+
+// execsRemove reports whether a find command runs rm on what it finds
+func execsRemove(args []string) bool {
+	for i, arg := range args {
+		if (arg == "-exec" || arg == "-execdir" || arg == "-ok") && i+1 < len(args) && path.Base(args[i+1]) == "rm" {
+			return true
+		}
+	}
+	return false
+}
+
+// criticalPath reports whether a path is the root, the home directory or a
+// top level system directory, or everything inside one of them
+func criticalPath(p string) bool {
+	if p == "*" {
+		// Everything in the current directory, which is usually intended
+		return false
+	}
+	p = strings.TrimSuffix(p, "*")
+	if p != "/" {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return criticalPaths[p] || p == "~/" || p == "$HOME/"
+}
+
+// anyCritical reports whether any of the paths is critical
+func anyCritical(paths []string) bool {
+	return firstCritical(paths) != ""
+}
+
+// firstCritical returns the first critical path, or empty if there is none
+func firstCritical(paths []string) string {
+	for _, p := range paths {
+		if criticalPath(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// worldWritable reports whether a chmod mode grants write access to others
+func worldWritable(mode string) bool {
+	if len(mode) >= 3 && strings.Trim(mode, "01234567") == "" {
+		last := mode[len(mode)-1]
+		return last == '2' || last == '3' || last == '6' || last == '7'
+	}
+	for _, grant := range []string{"o+w", "a+w", "o=rw", "a=rw", "o+rw", "a+rw"} {
+		if strings.Contains(mode, grant) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSensitive reports whether a path refers to credentials
+func isSensitive(p string) bool {
+	for _, sensitive := range sensitivePaths {
+		if strings.Contains(p, sensitive) || strings.HasSuffix(p, strings.TrimSuffix(sensitive, "/")) {
+			return true
+		}
+	}
+	return false
+}
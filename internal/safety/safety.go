@@ -0,0 +1,167 @@
+// Package safety classifies shell commands by the harm they can do, so that
+// destructive, privileged and data exfiltrating commands are not run without
+// the user noticing.
+package safety
+
+import (
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Level is how much harm a command can do
+type Level int
+
+// Risk levels
+const (
+	LevelLow Level = iota
+	LevelMedium
+	LevelHigh
+)
+
+// String returns the name of the level, matching the risk levels reported by
+// the LLM
+func (l Level) String() string {
+	switch l {
+	case LevelMedium:
+		return "medium"
+	case LevelHigh:
+		return "high"
+	default:
+		return "low"
+	}
+}
+
+// Category is the kind of harm a finding is about
+type Category string
+
+// Categories of findings
+const (
+	CategoryDestructive Category = "destructive"
+	CategoryPrivileged  Category = "privileged"
+	CategoryNetwork     Category = "network"
+	CategoryUnparsed    Category = "unparsed"
+)
+
+// Finding is one reason a command is risky
+type Finding struct {
+	Category Category
+	Level    Level
+	Reason   string
+}
+
+// Analysis is the result of analyzing a command
+type Analysis struct {
+	Command string
+
+	// Level is the highest level of the findings
+	Level    Level
+	Findings []Finding
+
+	// Denied is true if the command matches an entry of the deny list, which
+	// is held in DeniedBy
+	Denied   bool
+	DeniedBy string
+
+	// Allowed is true if the command matches an entry of the allow list
+	Allowed bool
+
+	// commands are the simple commands of the command line, as written and
+	// without the wrappers that run them, for the deny list
+	commands []string
+}
+
+// NeedsTypedConfirmation reports whether the user must explicitly type a
+// confirmation before the command is run, which is the case for high risk
+// commands that are not on the allow list
+func (a *Analysis) NeedsTypedConfirmation() bool {
+	return a.Level >= LevelHigh && !a.Allowed
+}
+
+// add records a finding
+func (a *Analysis) add(category Category, level Level, reason string) {
+	for _, finding := range a.Findings {
+		if finding.Reason == reason {
+			return
+		}
+	}
+	a.Findings = append(a.Findings, Finding{Category: category, Level: level, Reason: reason})
+	if level > a.Level {
+		a.Level = level
+	}
+}
+
+// Analyzer classifies commands with a fixed set of rules and the user's deny
+// and allow lists
+type Analyzer struct {
+	deny  []pattern
+	allow []pattern
+}
+
+// pattern is a deny or allow list entry
+type pattern struct {
+	entry string
+	re    *regexp.Regexp
+}
+
+// NewAnalyzer creates an analyzer for the given deny and allow lists. Entries
+// are matched against the whole command, "*" matches any text. Deny entries
+// are also matched against each command the line runs, such as git push in
+// cd repo && git push; allow entries are not, as allowing one command must not
+// allow the commands chained to it.
+func NewAnalyzer(deny, allow []string) *Analyzer {
+	return &Analyzer{
+		deny:  compilePatterns(deny),
+		allow: compilePatterns(allow),
+	}
+}
+
+// compilePatterns turns list entries into regular expressions
+func compilePatterns(entries []string) []pattern {
+	patterns := make([]pattern, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		expr := strings.ReplaceAll(regexp.QuoteMeta(entry), `\*`, ".*")
+		patterns = append(patterns, pattern{entry: entry, re: regexp.MustCompile("^" + expr + "$")})
+	}
+	return patterns
+}
+
+// match returns the first entry matching the command
+func match(patterns []pattern, command string) (string, bool) {
+	for _, p := range patterns {
+		if p.re.MatchString(command) {
+			return p.entry, true
+		}
+	}
+	return "", false
+}
+
+// Analyze classifies a command. A command that cannot be parsed is reported
+// as medium risk, as the rules cannot vouch for it.
+func (a *Analyzer) Analyze(command string) *Analysis {
+	command = strings.TrimSpace(command)
+	analysis := &Analysis{Command: command}
+
+	analysis.DeniedBy, analysis.Denied = match(a.deny, command)
+	_, analysis.Allowed = match(a.allow, command)
+
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		analysis.add(CategoryUnparsed, LevelMedium, "the command could not be parsed, review it carefully")
+		return analysis
+	}
+
+	checkFile(analysis, file)
+	for _, simple := range analysis.commands {
+		if analysis.Denied {
+			break
+		}
+		analysis.DeniedBy, analysis.Denied = match(a.deny, simple)
+	}
+	return analysis
+}
@@ -0,0 +1,164 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Session runs commands one after another as if they were typed into one
+// shell. Each command runs in a new shell process, which saves its state when
+// it exits: the working directory, exported variables and shell options. The
+// next command starts from that state, so `cd`, `export` and `set -o` carry
+// over.
+type Session struct {
+	shell    *Shell
+	sourceRC bool
+
+	// Dir is the working directory the next command runs in
+	Dir string
+
+	// stateDir holds the state saved by the last command
+	stateDir string
+}
+
+// NewSession starts a session in the current working directory. If sourceRC
+// is set, the user's rc file is sourced before each command.
+func (s *Shell) NewSession(sourceRC bool) (*Session, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	stateDir, err := os.MkdirTemp("", "shell-ai-session-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		shell:    s,
+		sourceRC: sourceRC,
+		Dir:      dir,
+		stateDir: stateDir,
+	}, nil
+}
+
+// Command returns a command that runs a command line in the session. Call
+// Update after it ran to pick up the state it left.
+func (s *Session) Command(command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	switch s.shell.Name {
+	case "bash", "zsh", "sh", "dash", "ash", "ksh", "mksh":
+		cmd = s.posixCommand(command)
+	case "fish":
+		cmd = s.fishCommand(command)
+	case "csh", "tcsh":
+		cmd = s.cshCommand(command)
+	default:
+		// Other shells give no way to save the state they leave, so the
+		// command runs with sh
+		fallback := &Session{shell: New(defaultPath), sourceRC: s.sourceRC, stateDir: s.stateDir}
+		cmd = fallback.posixCommand(command)
+	}
+	cmd.Dir = s.Dir
+	return cmd
+}
+
+// posixCommand returns a command for a POSIX shell. The state is saved from an
+// exit trap, so it is saved however the command ends, and it is restored both
+// before the rc file, which may need the environment, and after it, so values
+// the rc file changes, such as a $PATH it extends, are not changed twice. The
+// exported variables and the shell options are saved to separate files.
+//
+// Once exported variables were saved, the command runs with an empty
+// environment that they fill, so variables the user unset stay unset.
+func (s *Session) posixCommand(command string) *exec.Cmd {
+	options := "set +o"
+	if s.shell.Name == "bash" {
+		options += "; shopt -p"
+	}
+
+	var script strings.Builder
+	script.WriteString(`__shai_state=$1; `)
+	script.WriteString(`trap '__shai_status=$?; export -p >"$__shai_state/env" 2>/dev/null; { ` + options + `; } >"$__shai_state/options" 2>/dev/null; pwd >"$__shai_state/pwd"; exit $__shai_status' EXIT; `)
+
+	restore := `[ -r "$__shai_state/env" ] && . "$__shai_state/env" >/dev/null 2>&1; [ -r "$__shai_state/options" ] && . "$__shai_state/options" >/dev/null 2>&1; `
+	script.WriteString(restore)
+
+	rc := ""
+	if s.sourceRC {
+		rc = s.shell.rcFile()
+	}
+	if rc != "" {
+		if s.shell.Name == "bash" {
+			script.WriteString("shopt -s expand_aliases; ")
+		}
+		script.WriteString(`. "$2" >/dev/null 2>&1; `)
+		script.WriteString(restore)
+	}
+	script.WriteString(`eval "$3"`)
+
+	cmd := exec.Command(s.shell.Path, "-c", script.String(), s.shell.Name, s.stateDir, rc, command)
+	if info, err := os.Stat(filepath.Join(s.stateDir, "env")); err == nil && info.Size() > 0 {
+		cmd.Env = []string{}
+	}
+	return cmd
+}
+
+// fishCommand returns a command for fish, which saves only the working
+// directory, as its variables cannot be exported in a form that is read back
+func (s *Session) fishCommand(command string) *exec.Cmd {
+	script := "function __shai_save --on-event fish_exit\n" +
+		"    pwd >" + fishQuote(filepath.Join(s.stateDir, "pwd")) + "\n" +
+		"end\n" +
+		command
+	if !s.sourceRC {
+		return exec.Command(s.shell.Path, "--no-config", "-c", script)
+	}
+	return exec.Command(s.shell.Path, "-c", script)
+}
+
+// cshCommand returns a command for csh and tcsh, which saves only the working
+// directory, as they have no exit trap to save the rest from
+func (s *Session) cshCommand(command string) *exec.Cmd {
+	script := command + "\n" +
+		"set __shai_status = $status\n" +
+		"pwd >! " + cshQuote(filepath.Join(s.stateDir, "pwd")) + "\n" +
+		"exit $__shai_status"
+	return exec.Command(s.shell.Path, "-c", script)
+}
+
+// cshQuote quotes a string for csh
+func cshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, `'`, `'\''`) + "'"
+}
+
+// fishQuote quotes a string for fish
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// Update picks up the working directory the last command left. It is kept if
+// the command saved none, as happens when it replaced the shell with exec or
+// was killed.
+func (s *Session) Update() error {
+	data, err := os.ReadFile(filepath.Join(s.stateDir, "pwd"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if dir := strings.TrimRight(string(data), "\n"); dir != "" {
+		s.Dir = dir
+	}
+	return nil
+}
+
+// Close removes the saved state
+func (s *Session) Close() error {
+	return os.RemoveAll(s.stateDir)
+}
@@ -0,0 +1,177 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	for _, name := range []string{"bash", "dash", "zsh"} {
+		t.Run(name, func(t *testing.T) {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				t.Skipf("%s is not installed", name)
+			}
+
+			start := t.TempDir()
+			other := filepath.Join(t.TempDir(), "dir with spaces")
+			if err := os.Mkdir(other, 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			t.Setenv("SHAI_TEST_UNSET", "set")
+
+			session, err := (&Shell{Name: name, Path: path}).NewSession(false)
+			if err != nil {
+				t.Fatalf("NewSession() error = %v", err)
+			}
+			defer session.Close()
+			session.Dir = start
+
+			run := func(command string) string {
+				t.Helper()
+				out, err := session.Command(command).Output()
+				if err != nil {
+					t.Fatalf("Command(%q) error = %v", command, err)
+				}
+				if err := session.Update(); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+				return string(out)
+			}
+
+			run(`cd "` + other + `" && export SHAI_TEST_VAR='a b' && unset SHAI_TEST_UNSET && set -o noglob`)
+			if session.Dir != other {
+				t.Errorf("Dir = %q, want %q", session.Dir, other)
+			}
+
+			if got, want := run(`pwd; echo "$SHAI_TEST_VAR"; echo "${SHAI_TEST_UNSET-unset}"; echo *`), other+"\na b\nunset\n*\n"; got != want {
+				t.Errorf("state was not kept, output = %q, want %q", got, want)
+			}
+
+			// Programs the commands start see exported variables
+			if got := run("env"); !strings.Contains(got, "SHAI_TEST_VAR=a b\n") {
+				t.Errorf("env output = %q, want the exported variable", got)
+			}
+
+			if got := run("cd - >/dev/null; pwd"); got != start+"\n" {
+				t.Errorf("cd - output = %q, want %q", got, start+"\n")
+			}
+
+			// A failing command still saves its state and keeps its status
+			err = session.Command("cd / && exit 3").Run()
+			if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+				t.Errorf("Command() error = %v, want exit status 3", err)
+			}
+			if err := session.Update(); err != nil || session.Dir != "/" {
+				t.Errorf("Update() = %v, Dir = %q, want /", err, session.Dir)
+			}
+		})
+	}
+}
+
+func TestSessionKeepsDirectory(t *testing.T) {
+	// Shells without saved state still keep the working directory
+	for _, name := range []string{"tcsh", "csh", "nu"} {
+		t.Run(name, func(t *testing.T) {
+			path, err := exec.LookPath(name)
+			if err != nil && name != "nu" {
+				t.Skipf("%s is not installed", name)
+			}
+			if name == "nu" {
+				// Runs with sh, the shell itself is not needed
+				path = "/usr/bin/nu"
+			}
+
+			session, err := (&Shell{Name: name, Path: path}).NewSession(false)
+			if err != nil {
+				t.Fatalf("NewSession() error = %v", err)
+			}
+			defer session.Close()
+			session.Dir = t.TempDir()
+
+			other := t.TempDir()
+			err = session.Command("cd " + other + " && exit 3").Run()
+			if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+				t.Errorf("Command() error = %v, want exit status 3", err)
+			}
+			if err := session.Update(); err != nil || session.Dir != other {
+				t.Errorf("Update() = %v, Dir = %q, want %q", err, session.Dir, other)
+			}
+		})
+	}
+}
+
+func TestSessionWithoutExports(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("SHAI_TEST_VAR", "inherited")
+
+	session, err := (&Shell{Name: "bash", Path: bash}).NewSession(false)
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	defer session.Close()
+
+	// Options were saved but exported variables were not, the environment is
+	// inherited rather than left empty
+	if err := os.WriteFile(filepath.Join(session.stateDir, "options"), []byte("set -o noglob\n"), 0644); err != nil {
+		t.Fatalf("Failed to write options: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(session.stateDir, "env"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write env: %v", err)
+	}
+
+	out, err := session.Command(`echo "$SHAI_TEST_VAR" *`).Output()
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if got, want := string(out), "inherited *\n"; got != want {
+		t.Errorf("Command() output = %q, want %q", got, want)
+	}
+}
+
+func TestSessionSourcesRC(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	home := t.TempDir()
+	rc := "alias greet='echo hello'\nexport PATH=\"$HOME/bin:$PATH\"\n"
+	if err := os.WriteFile(filepath.Join(home, ".bashrc"), []byte(rc), 0644); err != nil {
+		t.Fatalf("Failed to write .bashrc: %v", err)
+	}
+	t.Setenv("HOME", home)
+
+	session, err := (&Shell{Name: "bash", Path: bash}).NewSession(true)
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	defer session.Close()
+
+	var paths []string
+	for i := 0; i < 2; i++ {
+		out, err := session.Command(`greet; echo "$PATH"`).Output()
+		if err != nil {
+			t.Fatalf("Command() error = %v", err)
+		}
+		if err := session.Update(); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		greeting, path, _ := strings.Cut(string(out), "\n")
+		if greeting != "hello" {
+			t.Errorf("Command() output = %q, want the alias to be expanded", out)
+		}
+		paths = append(paths, path)
+	}
+
+	// The rc file extends $PATH once, not once per command
+	if paths[0] != paths[1] {
+		t.Errorf("$PATH changed between commands: %q, then %q", paths[0], paths[1])
+	}
+}
@@ -0,0 +1,153 @@
+// Package shell detects the user's shell and runs commands with it, so the
+// syntax, aliases and functions the user relies on are available.
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// defaultPath is the shell used when $SHELL is not set
+const defaultPath = "/bin/sh"
+
+// Shell is a shell commands can be run with
+type Shell struct {
+	// Name is the name of the shell binary, such as "bash" or "zsh"
+	Name string
+
+	// Path is the path of the shell binary
+	Path string
+
+	// Version is the version reported by the shell, empty if it reports none
+	Version string
+}
+
+var (
+	detected   *Shell
+	detectOnce sync.Once
+)
+
+// Detect returns the user's shell as given by $SHELL, falling back to sh. The
+// result is cached, as finding the version runs the shell.
+func Detect() *Shell {
+	detectOnce.Do(func() {
+		detected = New(os.Getenv("SHELL"))
+	})
+	return detected
+}
+
+// New returns the shell at the given path, or sh if the path is empty
+func New(path string) *Shell {
+	if path == "" {
+		path = defaultPath
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".exe")
+	return &Shell{
+		Name:    name,
+		Path:    path,
+		Version: version(name, path),
+	}
+}
+
+// versionPattern matches the version number in the output of --version
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// version asks a shell for its version. Shells such as dash have no --version
+// flag and report none.
+func version(name, path string) string {
+	switch name {
+	case "bash", "zsh", "fish", "ksh", "mksh", "tcsh":
+	default:
+		return ""
+	}
+
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	firstLine, _, _ := strings.Cut(string(out), "\n")
+	return versionPattern.FindString(firstLine)
+}
+
+// String returns the name and version of the shell, such as "bash 5.2.15"
+func (s *Shell) String() string {
+	if s.Version == "" {
+		return s.Name
+	}
+	return s.Name + " " + s.Version
+}
+
+// Command returns a command that runs a command line with the shell. If
+// sourceRC is set, the user's rc file is sourced first so their aliases and
+// functions are available. Fish always reads its configuration.
+func (s *Shell) Command(command string, sourceRC bool) *exec.Cmd {
+	switch s.Name {
+	case "fish":
+		if !sourceRC {
+			return exec.Command(s.Path, "--no-config", "-c", command)
+		}
+		return exec.Command(s.Path, "-c", command)
+	case "bash", "zsh":
+		rc := s.rcFile()
+		if !sourceRC || rc == "" {
+			break
+		}
+
+		// Source the rc file and evaluate the command afterwards, so aliases
+		// it defines are expanded. The command is passed as an argument to
+		// avoid quoting it.
+		script := `. "$1" >/dev/null 2>&1; eval "$2"`
+		if s.Name == "bash" {
+			script = "shopt -s expand_aliases; " + script
+		}
+		return exec.Command(s.Path, "-c", script, s.Name, rc, command)
+	}
+
+	return exec.Command(s.Path, "-c", command)
+}
+
+// rcFile returns the path of the user's rc file for the shell, or empty if it
+// has none
+func (s *Shell) rcFile() string {
+	var rc string
+	switch s.Name {
+	case "bash":
+		rc = filepath.Join(os.Getenv("HOME"), ".bashrc")
+	case "zsh":
+		dir := os.Getenv("ZDOTDIR")
+		if dir == "" {
+			dir = os.Getenv("HOME")
+		}
+		rc = filepath.Join(dir, ".zshrc")
+	default:
+		return ""
+	}
+
+	if _, err := os.Stat(rc); err != nil {
+		return ""
+	}
+	return rc
+}
+
+// Variant returns the syntax commands for the shell are parsed with, and false
+// if they cannot be parsed. Zsh is not parsed as bash, which rejects zsh only
+// syntax such as glob qualifiers and short loops.
+func (s *Shell) Variant() (syntax.LangVariant, bool) {
+	switch s.Name {
+	case "bash":
+		return syntax.LangBash, true
+	case "sh", "dash", "ash":
+		return syntax.LangPOSIX, true
+	case "ksh", "mksh":
+		return syntax.LangMirBSDKorn, true
+	default:
+		return syntax.LangBash, false
+	}
+}
@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	sh := New("")
+	if sh.Name != "sh" || sh.Path != defaultPath {
+		t.Errorf("New(\"\") = %+v, want sh", sh)
+	}
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	sh = New(bash)
+	if sh.Name != "bash" {
+		t.Errorf("New(%q).Name = %q, want bash", bash, sh.Name)
+	}
+	if !versionPattern.MatchString(sh.Version) {
+		t.Errorf("New(%q).Version = %q, want a version number", bash, sh.Version)
+	}
+	if sh.String() != "bash "+sh.Version {
+		t.Errorf("String() = %q", sh.String())
+	}
+}
+
+func TestCommandSourcesRC(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	home := t.TempDir()
+	rc := "alias greet='echo hello from the rc file'\necho noise from the rc file\n"
+	if err := os.WriteFile(filepath.Join(home, ".bashrc"), []byte(rc), 0644); err != nil {
+		t.Fatalf("Failed to write .bashrc: %v", err)
+	}
+	t.Setenv("HOME", home)
+
+	sh := &Shell{Name: "bash", Path: bash}
+
+	out, err := sh.Command("greet | tr a-z A-Z", true).Output()
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "HELLO FROM THE RC FILE" {
+		t.Errorf("Command() output = %q, want the alias to be expanded", got)
+	}
+
+	// Without the rc file the alias is unknown
+	if err := sh.Command("greet", false).Run(); err == nil {
+		t.Error("Command() without the rc file error = nil, want the alias to be unknown")
+	}
+
+	// Bash specific syntax runs
+	out, err = sh.Command("echo {a,b}; [[ -n x ]] && echo ok", false).Output()
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if got := string(out); got != "a b\nok\n" {
+		t.Errorf("Command() output = %q, want bash syntax to work", got)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{name: "bash", ok: true},
+		{name: "zsh", ok: false},
+		{name: "sh", ok: true},
+		{name: "fish", ok: false},
+		{name: "nu", ok: false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := (&Shell{Name: tt.name}).Variant(); ok != tt.ok {
+			t.Errorf("Variant() for %s ok = %t, want %t", tt.name, ok, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+# Shell-AI integration for bash 4 or later, load it with `eval "$(shai init bash)"`
+# in ~/.bashrc
+#
+# Press Ctrl+G to replace the line being edited with the command you pick from
+# the suggestions for it. The command is not run until you press Enter.
+_shai_widget() {
+  [[ -z "$READLINE_LINE" ]] && return
+
+  local selected
+  selected=$(command shai --print --select -- "$READLINE_LINE" </dev/tty)
+  if [[ $? -eq 0 && -n "$selected" ]]; then
+    READLINE_LINE=$selected
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+
+bind -x '"\C-g": _shai_widget'
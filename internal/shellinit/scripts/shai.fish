@@ -0,0 +1,23 @@
+# Shell-AI integration for fish, load it with `shai init fish | source` in
+# ~/.config/fish/config.fish
+#
+# Press Ctrl+G to replace the line being edited with the command you pick from
+# the suggestions for it. The command is not run until you press Enter.
+function _shai_widget
+    set -l prompt (commandline)
+    if test -z "$prompt"
+        return
+    end
+
+    set -l selected (command shai --print --select -- "$prompt" </dev/tty)
+    if test $status -eq 0 -a -n "$selected"
+        commandline -r -- $selected
+        commandline -f end-of-line
+    end
+    commandline -f repaint
+end
+
+bind \cg _shai_widget
+if bind -M insert >/dev/null 2>&1
+    bind -M insert \cg _shai_widget
+end
@@ -0,0 +1,25 @@
+// Package shellinit provides the scripts that integrate shai with interactive
+// shells, so a picked command is placed in the line editor instead of being
+// run in a child shell.
+package shellinit
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+//go:embed scripts
+var scripts embed.FS
+
+// Shells are the shells an integration script exists for
+var Shells = []string{"zsh", "bash", "fish"}
+
+// Script returns the integration script for a shell
+func Script(shell string) (string, error) {
+	data, err := scripts.ReadFile("scripts/shai." + shell)
+	if err != nil {
+		return "", fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(Shells, ", "))
+	}
+	return string(data), nil
+}
@@ -0,0 +1,51 @@
+package shellinit
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	// Each script hands the line being edited to shai and writes the pick back
+	tests := map[string][]string{
+		"zsh":  {`shai --print --select -- "$BUFFER"`, "BUFFER=$selected", "bindkey"},
+		"bash": {`shai --print --select -- "$READLINE_LINE"`, "READLINE_LINE=$selected", "bind -x"},
+		"fish": {`shai --print --select -- "$prompt"`, "commandline -r", "bind \\cg"},
+	}
+
+	for _, shell := range Shells {
+		t.Run(shell, func(t *testing.T) {
+			script, err := Script(shell)
+			if err != nil {
+				t.Fatalf("Script() error = %v", err)
+			}
+			for _, want := range tests[shell] {
+				if !strings.Contains(script, want) {
+					t.Errorf("Script() does not contain %q", want)
+				}
+			}
+
+			// Check the syntax if the shell is installed
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				return
+			}
+			flag := "-n"
+			if shell == "fish" {
+				flag = "--no-execute"
+			}
+			cmd := exec.Command(path, flag)
+			cmd.Stdin = strings.NewReader(script)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s %s error = %v: %s", shell, flag, err, out)
+			}
+		})
+	}
+}
+
+func TestScriptUnsupportedShell(t *testing.T) {
+	if _, err := Script("tcsh"); err == nil {
+		t.Error("Script() error = nil, want an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,48 @@
+package suggestions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/safety"
+	"github.com/manifoldco/promptui"
+)
+
+// typedConfirmation is the answer the user must type to run a high risk command
+const typedConfirmation = "yes"
+
+// guardCommand analyzes a command before it is run, warns about what makes it
+// risky and reports whether it may be run. Commands on the deny list are never
+// run and high risk commands are only run once the user types the
+// confirmation, even when confirmation is otherwise skipped.
+func guardCommand(analyzer *safety.Analyzer, command string) (bool, error) {
+	analysis := analyzer.Analyze(command)
+
+	if analysis.Denied {
+		fmt.Printf("Refusing to run the command, it matches %q in SHAI_DENY_LIST\n", analysis.DeniedBy)
+		return false, nil
+	}
+
+	if analysis.Level >= safety.LevelMedium {
+		for _, finding := range analysis.Findings {
+			fmt.Printf("Warning (%s risk): %s\n", finding.Level, finding.Reason)
+		}
+	}
+
+	if !analysis.NeedsTypedConfirmation() {
+		return true, nil
+	}
+
+	confirmPrompt := promptui.Prompt{
+		Label: fmt.Sprintf("This command is high risk, type %q to run it", typedConfirmation),
+	}
+	answer, err := confirmPrompt.Run()
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(strings.ToLower(answer)) != typedConfirmation {
+		fmt.Println("Not running the command.")
+		return false, nil
+	}
+	return true, nil
+}
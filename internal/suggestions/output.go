@@ -0,0 +1,173 @@
+package suggestions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/llm"
+	"github.com/jwswj/shell-ai/internal/safety"
+	"github.com/manifoldco/promptui"
+)
+
+// OutputFormat is how suggestions are written when the menu is skipped
+type OutputFormat int
+
+// Output formats
+const (
+	// OutputPlain writes one command per line
+	OutputPlain OutputFormat = iota
+
+	// OutputJSON writes a JSON array of suggestions with their metadata
+	OutputJSON
+)
+
+// suggestionOutput is a suggestion as written by OutputJSON. Every field is
+// always present so scripts can rely on the format.
+type suggestionOutput struct {
+	Command       string       `json:"command"`
+	Explanation   string       `json:"explanation"`
+	Risk          string       `json:"risk"`
+	Requires      []string     `json:"requires"`
+	ModifiesFiles bool         `json:"modifies_files"`
+	Provider      string       `json:"provider"`
+	Missing       []string     `json:"missing"`
+	Safety        safetyOutput `json:"safety"`
+}
+
+// safetyOutput is the local safety analysis of a suggestion
+type safetyOutput struct {
+	Level    string          `json:"level"`
+	Findings []findingOutput `json:"findings"`
+}
+
+// findingOutput is one finding of the safety analysis
+type findingOutput struct {
+	Category string `json:"category"`
+	Level    string `json:"level"`
+	Reason   string `json:"reason"`
+}
+
+// PrintOptions controls how Print writes suggestions
+type PrintOptions struct {
+	Format OutputFormat
+
+	// First writes only the first suggestion
+	First bool
+
+	// Select lets the user pick the suggestion to write from a menu drawn on
+	// stderr, so shell integrations can capture the pick from stdout
+	Select bool
+}
+
+// Print generates suggestions and writes them to w instead of running them.
+// Suggestions matching the deny list are left out. ErrNoSuggestions is
+// returned if there is nothing to write.
+func Print(ctx context.Context, client *llm.Client, cfg *config.Config, promptArgs []string, w io.Writer, opts PrintOptions) error {
+	prompt := strings.Join(promptArgs, " ")
+
+	suggestions, err := generateSuggestions(ctx, client, cfg, prompt, false)
+	if err != nil {
+		return err
+	}
+
+	analyzer := safety.NewAnalyzer(cfg.DenyPatterns(), cfg.AllowPatterns())
+	outputs := make([]suggestionOutput, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		analysis := analyzer.Analyze(suggestion.Command)
+		if analysis.Denied {
+			cfg.DebugPrint("Leaving out %q, it matches %q in SHAI_DENY_LIST\n", suggestion.Command, analysis.DeniedBy)
+			continue
+		}
+		outputs = append(outputs, newSuggestionOutput(suggestion, analysis))
+	}
+	if len(outputs) == 0 {
+		return ErrNoSuggestions
+	}
+	switch {
+	case opts.First:
+		outputs = outputs[:1]
+	case opts.Select && len(outputs) > 1:
+		index, err := selectOutput(outputs, len(cfg.ProviderChain()) > 1)
+		if err != nil {
+			return err
+		}
+		outputs = outputs[index : index+1]
+	}
+
+	return writeSuggestions(w, outputs, opts.Format)
+}
+
+// selectOutput lets the user pick a suggestion from a menu drawn on stderr. An
+// interrupted pick is reported as context.Canceled.
+func selectOutput(outputs []suggestionOutput, showProvider bool) (int, error) {
+	selectPrompt := promptui.Select{
+		Label:     "Select a command",
+		Items:     outputs,
+		Size:      10,
+		Templates: menuTemplates(showProvider),
+		Stdout:    os.Stderr,
+	}
+
+	index, _, err := selectPrompt.Run()
+	if err != nil {
+		// Check if the error is due to Ctrl+C (interrupt)
+		if err.Error() == "^C" || strings.Contains(err.Error(), "interrupt") {
+			return 0, context.Canceled
+		}
+		return 0, err
+	}
+	return index, nil
+}
+
+// newSuggestionOutput converts a suggestion and its safety analysis to the
+// JSON output format
+func newSuggestionOutput(suggestion Suggestion, analysis *safety.Analysis) suggestionOutput {
+	output := suggestionOutput{
+		Command:       suggestion.Command,
+		Explanation:   suggestion.Explanation,
+		Risk:          suggestion.Risk,
+		Requires:      suggestion.Requires,
+		ModifiesFiles: suggestion.ModifiesFiles,
+		Provider:      suggestion.Provider,
+		Missing:       suggestion.Missing,
+		Safety: safetyOutput{
+			Level:    analysis.Level.String(),
+			Findings: make([]findingOutput, 0, len(analysis.Findings)),
+		},
+	}
+	if output.Requires == nil {
+		output.Requires = []string{}
+	}
+	if output.Missing == nil {
+		output.Missing = []string{}
+	}
+	for _, finding := range analysis.Findings {
+		output.Safety.Findings = append(output.Safety.Findings, findingOutput{
+			Category: string(finding.Category),
+			Level:    finding.Level.String(),
+			Reason:   finding.Reason,
+		})
+	}
+	return output
+}
+
+// writeSuggestions writes suggestions in the given format
+func writeSuggestions(w io.Writer, outputs []suggestionOutput, format OutputFormat) error {
+	if format == OutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(outputs)
+	}
+
+	for _, output := range outputs {
+		if _, err := fmt.Fprintln(w, output.Command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
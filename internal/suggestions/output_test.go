@@ -0,0 +1,162 @@
+package suggestions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/llm"
+)
+
+// newTestClient creates a client for a server that responds with the given
+// message contents in turn, repeating the last one
+func newTestClient(t *testing.T, cfg *config.Config, contents ...string) *llm.Client {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		content := contents[min(requests, len(contents)-1)]
+		requests++
+		mu.Unlock()
+
+		body, _ := json.Marshal(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": content}}},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	// Keep the tool index and platform probe out of the user's cache directory
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	cfg.APIProvider = "openai"
+	cfg.OpenAIAPIKey = "test-key"
+	cfg.OpenAIModel = "gpt-4o-mini"
+	cfg.OpenAIAPIBase = srv.URL
+	client, err := llm.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestPrint(t *testing.T) {
+	content := `{"commands": [
+		{"command": "ls -la", "explanation": "list files", "risk": "low", "requires": ["ls"], "modifies_files": false},
+		{"command": "git push --force", "explanation": "force push", "risk": "high", "requires": ["git"], "modifies_files": false},
+		{"command": "rm -rf ./build", "explanation": "delete the build", "risk": "medium", "requires": ["rm"], "modifies_files": true}
+	]}`
+
+	tests := []struct {
+		name   string
+		format OutputFormat
+		first  bool
+		want   string
+	}{
+		{name: "plain", format: OutputPlain, want: "ls -la\nrm -rf ./build\n"},
+		{name: "plain first", format: OutputPlain, first: true, want: "ls -la\n"},
+		{name: "json first", format: OutputJSON, first: true, want: `[
+  {
+    "command": "ls -la",
+    "explanation": "list files",
+    "risk": "low",
+    "requires": [
+      "ls"
+    ],
+    "modifies_files": false,
+    "provider": "openai",
+    "missing": [
+      "ls"
+    ],
+    "safety": {
+      "level": "low",
+      "findings": []
+    }
+  }
+]
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{SuggestionCount: 3, BatchSuggestions: true, DenyList: "git push*"}
+			client := newTestClient(t, cfg, content)
+
+			// Nothing is on $PATH, so every binary is reported missing
+			t.Setenv("PATH", t.TempDir())
+
+			var out bytes.Buffer
+			if err := Print(context.Background(), client, cfg, []string{"clean", "up"}, &out, PrintOptions{Format: tt.format, First: tt.first}); err != nil {
+				t.Fatalf("Print() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Print() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintSafetyFindings(t *testing.T) {
+	cfg := &config.Config{SuggestionCount: 1}
+	client := newTestClient(t, cfg, `{"command": "sudo rm -rf /"}`)
+
+	var out bytes.Buffer
+	if err := Print(context.Background(), client, cfg, []string{"wipe"}, &out, PrintOptions{Format: OutputJSON}); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	var got []suggestionOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode output: %v", err)
+	}
+	if len(got) != 1 || got[0].Safety.Level != "high" || len(got[0].Safety.Findings) == 0 {
+		t.Errorf("Print() = %+v, want a high risk suggestion with findings", got)
+	}
+}
+
+func TestPrintNoSuggestions(t *testing.T) {
+	cfg := &config.Config{SuggestionCount: 1, DenyList: "*"}
+	client := newTestClient(t, cfg, `{"command": "ls"}`)
+
+	var out bytes.Buffer
+	err := Print(context.Background(), client, cfg, []string{"list"}, &out, PrintOptions{})
+	if !errors.Is(err, ErrNoSuggestions) {
+		t.Errorf("Print() error = %v, want ErrNoSuggestions", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Print() wrote %q, want nothing", out.String())
+	}
+}
+
+func TestPrintBatchDuplicates(t *testing.T) {
+	// The batch repeats a command, the missing suggestions are requested one
+	// at a time
+	cfg := &config.Config{SuggestionCount: 3, BatchSuggestions: true}
+	client := newTestClient(t, cfg,
+		`{"commands": [{"command": "ls"}, {"command": "ls"}, {"command": "ls"}]}`,
+		`{"command": "ls -la"}`,
+		`{"command": "ls -lh"}`,
+		`{"command": "ls"}`,
+	)
+
+	var out bytes.Buffer
+	if err := Print(context.Background(), client, cfg, []string{"list"}, &out, PrintOptions{}); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(got)
+	if want := []string{"ls", "ls -la", "ls -lh"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Print() = %q, want %q", got, want)
+	}
+}
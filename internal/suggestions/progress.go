@@ -0,0 +1,181 @@
+package suggestions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spinnerFrames are the frames of the spinner shown for pending suggestions
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// maxProgressWidth is the widest command shown in the progress view, longer
+// commands would wrap and break redrawing
+const maxProgressWidth = 76
+
+// slotState is the state of one suggestion slot in the progress view
+type slotState int
+
+const (
+	slotPending slotState = iota
+	slotReady
+	slotFailed
+)
+
+// progressView renders one line per suggestion slot while suggestions are
+// being generated. Slots show their command as soon as it is complete and a
+// spinner until then.
+type progressView struct {
+	mu       sync.Mutex
+	out      io.Writer
+	commands []string
+	states   []slotState
+	frame    int
+	drawn    int
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// newProgressView creates a progress view with the given number of slots
+func newProgressView(out io.Writer, slots int) *progressView {
+	return &progressView{
+		out:      out,
+		commands: make([]string, slots),
+		states:   make([]slotState, slots),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Start starts animating the view
+func (v *progressView) Start() {
+	v.mu.Lock()
+	v.draw()
+	v.mu.Unlock()
+
+	go func() {
+		defer close(v.stopped)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-v.stop:
+				return
+			case <-ticker.C:
+				v.mu.Lock()
+				v.frame = (v.frame + 1) % len(spinnerFrames)
+				v.draw()
+				v.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Set shows a completed command in the given slot. Set, Fail, Reset and Stop
+// are no-ops on a nil view so callers need not check whether progress is shown.
+func (v *progressView) Set(slot int, command string) {
+	if v == nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if slot >= len(v.states) || v.states[slot] != slotPending {
+		return
+	}
+	v.commands[slot] = command
+	v.states[slot] = slotReady
+	v.draw()
+}
+
+// Fail marks the given slot as failed
+func (v *progressView) Fail(slot int) {
+	if v == nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if slot >= len(v.states) || v.states[slot] != slotPending {
+		return
+	}
+	v.states[slot] = slotFailed
+	v.draw()
+}
+
+// Reset marks all slots as pending again
+func (v *progressView) Reset() {
+	if v == nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for i := range v.states {
+		v.commands[i] = ""
+		v.states[i] = slotPending
+	}
+	v.draw()
+}
+
+// Stop stops the animation and erases the view so the menu can take its place
+func (v *progressView) Stop() {
+	if v == nil {
+		return
+	}
+
+	close(v.stop)
+	<-v.stopped
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clear()
+}
+
+// draw redraws all slots, the caller must hold the lock
+func (v *progressView) draw() {
+	v.clear()
+	for i, command := range v.commands {
+		switch v.states[i] {
+		case slotReady:
+			fmt.Fprintf(v.out, "  \033[36m%s\033[0m\n", truncate(strings.ReplaceAll(command, "\n", " "), maxProgressWidth))
+		case slotFailed:
+			fmt.Fprintf(v.out, "  \033[31m✗ failed\033[0m\n")
+		default:
+			fmt.Fprintf(v.out, "  %s generating...\n", spinnerFrames[v.frame])
+		}
+	}
+	v.drawn = len(v.commands)
+}
+
+// clear erases the lines drawn last, the caller must hold the lock
+func (v *progressView) clear() {
+	for ; v.drawn > 0; v.drawn-- {
+		fmt.Fprint(v.out, "\033[1A\033[2K")
+	}
+}
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
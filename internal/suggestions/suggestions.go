@@ -1,17 +1,24 @@
 package suggestions
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/jwswj/shell-ai/internal/config"
+	"github.com/jwswj/shell-ai/internal/console"
 	"github.com/jwswj/shell-ai/internal/llm"
 	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/safety"
+	"github.com/jwswj/shell-ai/internal/shell"
+	"github.com/jwswj/shell-ai/internal/tools"
 	"github.com/manifoldco/promptui"
 )
 
@@ -28,14 +35,48 @@ const (
 // TextEditors is a list of common text editors
 var TextEditors = []string{"vi", "vim", "emacs", "nano", "ed", "micro", "joe", "nvim"}
 
-// ContextManager is the global context manager
-var ContextManager = parser.NewContextManager()
+// Suggestion is a command suggested by the LLM, along with the explanation,
+// risk level and required tools it reported
+type Suggestion struct {
+	parser.CommandResponse
+
+	// Provider is the name of the provider that suggested the command
+	Provider string
+
+	// Missing are the binaries the command runs that are not on $PATH
+	Missing []string
+}
+
+// ErrNoSuggestions is returned when no usable suggestion was generated
+var ErrNoSuggestions = errors.New("no suggestions were generated")
+
+// maxSyntaxRetries is how many times suggestions are requested again when some
+// of them were dropped for invalid shell syntax
+const maxSyntaxRetries = 1
 
 // Run runs the suggestions engine
-func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
+func Run(ctx context.Context, client *llm.Client, cfg *config.Config, promptArgs []string) error {
 	// Join prompt arguments into a single string
 	prompt := strings.Join(promptArgs, " ")
 
+	// Run selected commands with the user's shell
+	userShell := shell.Detect()
+
+	// Check selected commands against the safety rules and the user's lists
+	analyzer := safety.NewAnalyzer(cfg.DenyPatterns(), cfg.AllowPatterns())
+
+	// In context mode, keep the working directory, variables and options a
+	// command leaves for the next command
+	var session *shell.Session
+	if cfg.ContextMode {
+		var err error
+		session, err = userShell.NewSession(cfg.SourceRC)
+		if err != nil {
+			return fmt.Errorf("failed to start shell session: %w", err)
+		}
+		defer session.Close()
+	}
+
 	// Show warning if context mode is enabled
 	if cfg.ContextMode {
 		fmt.Printf("WARNING Context mode: data will be sent to the LLM, be careful if any sensitive data...\n\n")
@@ -44,31 +85,32 @@ func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
 
 	for {
 		// Generate suggestions
-		suggestions, err := generateSuggestions(client, cfg, prompt)
+		suggestions, err := generateSuggestions(ctx, client, cfg, prompt, true)
 		if err != nil {
+			// Check if generation was interrupted with Ctrl+C
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("\nExiting...")
+				return nil
+			}
 			return err
 		}
 
 		// Add only the Dismiss system option
-		options := append(suggestions, string(OptDismiss))
+		options := append(suggestions, Suggestion{CommandResponse: parser.CommandResponse{Command: string(OptDismiss)}})
 
 		// Create a select prompt with promptui
 		selectPrompt := promptui.Select{
-			Label: "Select a command",
-			Items: options,
-			Size:  10, // Show 10 items at a time
-			Templates: &promptui.SelectTemplates{
-				Active:   "→ {{ if eq . \"Dismiss\" }}{{ . | red }}{{ else }}{{ . | cyan }}{{ end }}",
-				Inactive: "  {{ if eq . \"Dismiss\" }}{{ . | red }}{{ else }}{{ . }}{{ end }}",
-				Selected: "✓ {{ if eq . \"Dismiss\" }}{{ . | red }}{{ else }}{{ . | green }}{{ end }}",
-			},
+			Label:     "Select a command",
+			Items:     options,
+			Size:      10, // Show 10 items at a time
+			Templates: menuTemplates(len(cfg.ProviderChain()) > 1),
 			Searcher: func(input string, index int) bool {
 				option := options[index]
-				return strings.Contains(strings.ToLower(option), strings.ToLower(input))
+				return strings.Contains(strings.ToLower(option.Command), strings.ToLower(input))
 			},
 		}
 
-		_, selection, err := selectPrompt.Run()
+		index, _, err := selectPrompt.Run()
 		if err != nil {
 			// Check if the error is due to Ctrl+C (interrupt)
 			if err.Error() == "^C" || strings.Contains(err.Error(), "interrupt") {
@@ -79,12 +121,13 @@ func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
 		}
 
 		// Handle selection
-		switch SystemOption(selection) {
+		selection := options[index]
+		switch SystemOption(selection.Command) {
 		case OptDismiss:
 			return nil
 		default:
 			// User selected a command
-			userCommand := selection
+			userCommand := selection.Command
 
 			// Confirm command if not skipping confirmation
 			if !cfg.SkipConfirm {
@@ -106,8 +149,23 @@ func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
 				userCommand = confirmedCommand
 			}
 
+			// Check the command for dangerous patterns, high risk commands
+			// are confirmed even when skipping confirmation
+			allowed, err := guardCommand(analyzer, userCommand)
+			if err != nil {
+				// Check if the error is due to Ctrl+C (interrupt)
+				if err.Error() == "^C" || strings.Contains(err.Error(), "interrupt") {
+					fmt.Println("\nExiting...")
+					return nil
+				}
+				return err
+			}
+			if !allowed && !cfg.ContextMode {
+				return nil
+			}
+
 			// Write to shell history if not skipping history
-			if !cfg.SkipHistory {
+			if allowed && !cfg.SkipHistory {
 				err = writeToShellHistory(userCommand)
 				if err != nil {
 					fmt.Printf("Warning: %s\n", err)
@@ -117,7 +175,7 @@ func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
 			// Execute command
 			if !cfg.ContextMode {
 				// Default mode - execute and exit
-				cmd := exec.Command("sh", "-c", userCommand)
+				cmd := userShell.Command(userCommand, cfg.SourceRC)
 				cmd.Stdin = os.Stdin
 				cmd.Stdout = os.Stdout
 				cmd.Stderr = os.Stderr
@@ -127,37 +185,43 @@ func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
 				}
 				return nil
 			} else {
-				// Context mode - capture output and continue
-				if startsWithAny(userCommand, TextEditors) {
-					// For text editors, just run the command directly
-					cmd := exec.Command("sh", "-c", userCommand)
-					cmd.Stdin = os.Stdin
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					err = cmd.Run()
-					if err != nil {
-						fmt.Printf("Error executing command: %v\n", err)
+				// Context mode - capture output and continue, a command that
+				// was not allowed is skipped
+				if allowed {
+					var output string
+					if startsWithAny(userCommand, TextEditors) {
+						// For text editors, just run the command directly
+						cmd := session.Command(userCommand)
+						cmd.Stdin = os.Stdin
+						cmd.Stdout = os.Stdout
+						cmd.Stderr = os.Stderr
+						err = cmd.Run()
+						if err != nil {
+							fmt.Printf("Error executing command: %v\n", err)
+						}
+					} else {
+						// For other commands, show the output as it arrives and
+						// capture it as context
+						output, err = console.Run(session.Command(userCommand))
+						if err != nil {
+							fmt.Printf("Error executing command: %v\n", err)
+						}
 					}
-				} else if strings.HasPrefix(userCommand, "cd") {
-					// Handle cd command specially
-					path := strings.TrimSpace(strings.TrimPrefix(userCommand, "cd"))
-					path = os.ExpandEnv(path)
-					path = filepath.Clean(path)
-					err = os.Chdir(path)
-					if err != nil {
+
+					// Remember the step, so follow up requests can refer to it
+					client.Remember(parser.Turn{
+						Prompt:   prompt,
+						Command:  userCommand,
+						ExitCode: exitCode(err),
+						Output:   output,
+					})
+
+					// Follow the directory the command changed to
+					if err := session.Update(); err != nil {
+						fmt.Printf("Warning: %s\n", err)
+					} else if err := os.Chdir(session.Dir); err != nil {
 						fmt.Printf("Error changing directory: %v\n", err)
 					}
-				} else {
-					// For other commands, capture output
-					cmd := exec.Command("sh", "-c", userCommand)
-					output, err := cmd.CombinedOutput()
-					if err != nil {
-						fmt.Printf("Error executing command: %v\n", err)
-					}
-					if len(output) > 0 {
-						fmt.Printf("\n%s", string(output))
-					}
-					ContextManager.AddChunk(string(output))
 				}
 
 				// Prompt for new command
@@ -187,12 +251,134 @@ func Run(client *llm.Client, cfg *config.Config, promptArgs []string) error {
 	}
 }
 
-// generateSuggestions generates shell command suggestions
-func generateSuggestions(client *llm.Client, cfg *config.Config, prompt string) ([]string, error) {
+// generateSuggestions generates shell command suggestions. Suggestions that
+// are not valid shell syntax are dropped and requested again. Progress is shown
+// if showProgress is set and stdout is a terminal. Pressing Ctrl+C while
+// suggestions are generated cancels the requests in flight.
+func generateSuggestions(ctx context.Context, client *llm.Client, cfg *config.Config, prompt string, showProgress bool) ([]Suggestion, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	// Show suggestions as they stream in when writing to a terminal
+	var progress *progressView
+	if showProgress && cfg.Stream && isTerminal(os.Stdout) {
+		progress = newProgressView(os.Stdout, cfg.SuggestionCount)
+		progress.Start()
+	}
+	defer progress.Stop()
+
+	var suggestions []Suggestion
+	for attempt := 0; attempt <= maxSyntaxRetries; attempt++ {
+		if attempt > 0 {
+			progress.Reset()
+		}
+
+		requested, err := requestSuggestions(ctx, client, cfg, prompt, progress)
+		if err != nil {
+			// Keep the valid suggestions of an earlier attempt
+			if len(suggestions) > 0 && ctx.Err() == nil {
+				break
+			}
+			return nil, err
+		}
+
+		valid, dropped := validateSuggestions(requested, shell.Detect(), tools.Load())
+		suggestions = deduplicate(append(suggestions, valid...))
+		if dropped == 0 || len(suggestions) >= cfg.SuggestionCount {
+			break
+		}
+		cfg.DebugPrint("Dropped %d suggestions with invalid shell syntax\n", dropped)
+	}
+
+	if len(suggestions) == 0 {
+		return nil, fmt.Errorf("%w: none was a valid shell command", ErrNoSuggestions)
+	}
+
+	// Prefer the suggestions that only use installed binaries
+	rankSuggestions(suggestions)
+	if len(suggestions) > cfg.SuggestionCount {
+		suggestions = suggestions[:cfg.SuggestionCount]
+	}
+	return suggestions, nil
+}
+
+// requestSuggestions requests suggestions from the LLM, in a single request if
+// possible
+func requestSuggestions(ctx context.Context, client *llm.Client, cfg *config.Config, prompt string, progress *progressView) ([]Suggestion, error) {
+	// Ask for all suggestions in a single request if possible
+	if cfg.BatchSuggestions && cfg.SuggestionCount > 1 {
+		suggestions, err := generateBatch(ctx, client, cfg, prompt, progress)
+		if err == nil && len(suggestions) >= cfg.SuggestionCount {
+			return suggestions, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			err = fmt.Errorf("only %d of %d suggestions were distinct", len(suggestions), cfg.SuggestionCount)
+		}
+		cfg.DebugPrint("Falling back to one request per suggestion: %v\n", err)
+		progress.Reset()
+
+		// Keep the suggestions of the batch, the ones requested separately
+		// may repeat them
+		more, err := generateFanOut(ctx, client, cfg, prompt, progress)
+		if err != nil {
+			if len(suggestions) > 0 && ctx.Err() == nil {
+				return suggestions, nil
+			}
+			return nil, err
+		}
+		return deduplicate(append(suggestions, more...)), nil
+	}
+
+	return generateFanOut(ctx, client, cfg, prompt, progress)
+}
+
+// generateBatch generates all suggestions with a single request
+func generateBatch(ctx context.Context, client *llm.Client, cfg *config.Config, prompt string, progress *progressView) ([]Suggestion, error) {
+	// Show each command of the list in the progress view as soon as it is
+	// complete
+	var onDelta func(int, string)
+	if progress != nil {
+		var partial strings.Builder
+		onDelta = func(_ int, delta string) {
+			partial.WriteString(delta)
+			for i, command := range parser.ParsePartialCommands(partial.String()) {
+				progress.Set(i, command)
+			}
+		}
+	}
+
+	completion, err := client.StreamShellCommands(ctx, prompt, cfg.SuggestionCount, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse responses
+	commands, err := completion.Commands()
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]Suggestion, 0, len(commands))
+	for _, command := range commands {
+		suggestions = append(suggestions, Suggestion{CommandResponse: command, Provider: completion.Provider})
+	}
+
+	// Deduplicate suggestions
+	suggestions = deduplicate(suggestions)
+	if len(suggestions) > cfg.SuggestionCount {
+		suggestions = suggestions[:cfg.SuggestionCount]
+	}
+	return suggestions, nil
+}
+
+// generateFanOut generates suggestions with one request per suggestion
+func generateFanOut(ctx context.Context, client *llm.Client, cfg *config.Config, prompt string, progress *progressView) ([]Suggestion, error) {
 	// Generate suggestions in parallel
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	suggestions := make([]string, 0, cfg.SuggestionCount)
+	suggestions := make([]Suggestion, 0, cfg.SuggestionCount)
 	errors := make([]error, 0)
 
 	// Limit concurrency to 4
@@ -205,22 +391,36 @@ func generateSuggestions(client *llm.Client, cfg *config.Config, prompt string)
 	sem := make(chan struct{}, maxWorkers)
 
 	for i := 0; i < cfg.SuggestionCount; i++ {
+		// Acquire semaphore, stop starting requests once cancelled
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
-		sem <- struct{}{} // Acquire semaphore
 
-		go func() {
+		go func(slot int) {
 			defer wg.Done()
 			defer func() { <-sem }() // Release semaphore
 
-			// Get context if enabled
-			var context string
-			if cfg.ContextMode {
-				context = ContextManager.GetContext()
+			// Show the command in the progress view as soon as it is complete
+			var onDelta func(string)
+			if progress != nil {
+				var partial strings.Builder
+				onDelta = func(delta string) {
+					partial.WriteString(delta)
+					if command, ok := parser.ParsePartialCommand(partial.String()); ok {
+						progress.Set(slot, command)
+					}
+				}
 			}
 
 			// Generate suggestion
-			response, err := client.GenerateShellCommand(prompt, context)
+			completion, err := client.StreamShellCommand(ctx, prompt, onDelta)
 			if err != nil {
+				progress.Fail(slot)
 				mu.Lock()
 				errors = append(errors, err)
 				mu.Unlock()
@@ -228,8 +428,9 @@ func generateSuggestions(client *llm.Client, cfg *config.Config, prompt string)
 			}
 
 			// Parse response
-			command, err := parser.ParseLLMResponse(response)
+			commands, err := completion.Commands()
 			if err != nil {
+				progress.Fail(slot)
 				mu.Lock()
 				errors = append(errors, err)
 				mu.Unlock()
@@ -237,34 +438,39 @@ func generateSuggestions(client *llm.Client, cfg *config.Config, prompt string)
 			}
 
 			// Add suggestion
-			if command != "" {
-				mu.Lock()
-				suggestions = append(suggestions, command)
-				mu.Unlock()
-			}
-		}()
+			command := commands[0]
+			progress.Set(slot, command.Command)
+			mu.Lock()
+			suggestions = append(suggestions, Suggestion{CommandResponse: command, Provider: completion.Provider})
+			mu.Unlock()
+		}(i)
 	}
 
 	// Wait for all goroutines to finish
 	wg.Wait()
 
+	// Discard partial results if cancelled
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
 	// Check if we have any suggestions
 	if len(suggestions) == 0 && len(errors) > 0 {
-		return nil, fmt.Errorf("failed to generate suggestions: %v", errors[0])
+		return nil, fmt.Errorf("failed to generate suggestions: %w", errors[0])
 	}
 
 	// Deduplicate suggestions
 	return deduplicate(suggestions), nil
 }
 
-// deduplicate removes duplicate strings from a slice
-func deduplicate(slice []string) []string {
+// deduplicate removes suggestions with duplicate commands from a slice
+func deduplicate(slice []Suggestion) []Suggestion {
 	seen := make(map[string]struct{})
-	result := make([]string, 0, len(slice))
+	result := make([]Suggestion, 0, len(slice))
 
 	for _, item := range slice {
-		if _, ok := seen[item]; !ok {
-			seen[item] = struct{}{}
+		if _, ok := seen[item.Command]; !ok {
+			seen[item.Command] = struct{}{}
 			result = append(result, item)
 		}
 	}
@@ -272,6 +478,33 @@ func deduplicate(slice []string) []string {
 	return result
 }
 
+// detailsTemplate shows what the LLM reported about the highlighted command
+const detailsTemplate = `{{ if ne .Command "Dismiss" }}
+--------- Details ----------
+{{ if .Explanation }}{{ .Explanation }}
+{{ end }}{{ "Risk:" | faint }}	{{ if eq .Risk "high" }}{{ .Risk | red }}{{ else if eq .Risk "medium" }}{{ .Risk | yellow }}{{ else if .Risk }}{{ .Risk | green }}{{ else }}unknown{{ end }}
+{{ "Modifies files:" | faint }}	{{ if .ModifiesFiles }}{{ "yes" | yellow }}{{ else }}no{{ end }}
+{{ if .Requires }}{{ "Requires:" | faint }}	{{ range $i, $tool := .Requires }}{{ if $i }}, {{ end }}{{ $tool }}{{ end }}
+{{ end }}{{ if .Missing }}{{ "Not installed:" | faint }}	{{ range $i, $tool := .Missing }}{{ if $i }}, {{ end }}{{ $tool | yellow }}{{ end }}
+{{ end }}{{ end }}`
+
+// menuTemplates returns the templates of the suggestions menu. Commands that
+// run binaries missing from $PATH are marked, and the provider that produced
+// each suggestion is shown when a fallback chain is configured.
+func menuTemplates(showProvider bool) *promptui.SelectTemplates {
+	suffix := "{{ if .Missing }} {{ \"(not installed)\" | yellow }}{{ end }}"
+	if showProvider {
+		suffix += "{{ if .Provider }} {{ printf \"(%s)\" .Provider | faint }}{{ end }}"
+	}
+
+	return &promptui.SelectTemplates{
+		Active:   "→ {{ if eq .Command \"Dismiss\" }}{{ .Command | red }}{{ else }}{{ .Command | cyan }}" + suffix + "{{ end }}",
+		Inactive: "  {{ if eq .Command \"Dismiss\" }}{{ .Command | red }}{{ else }}{{ .Command }}" + suffix + "{{ end }}",
+		Selected: "✓ {{ if eq .Command \"Dismiss\" }}{{ .Command | red }}{{ else }}{{ .Command | green }}{{ end }}",
+		Details:  detailsTemplate,
+	}
+}
+
 // writeToShellHistory writes a command to the shell history
 func writeToShellHistory(command string) error {
 	shell := os.Getenv("SHELL")
@@ -324,6 +557,20 @@ func writeToShellHistory(command string) error {
 	return err
 }
 
+// exitCode returns the exit status of a command from the error running it
+// returned, -1 if it could not be run
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	default:
+		return -1
+	}
+}
+
 // getCurrentDir returns the current directory
 func getCurrentDir() string {
 	dir, err := os.Getwd()
@@ -0,0 +1,107 @@
+package suggestions
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jwswj/shell-ai/internal/shell"
+	"github.com/jwswj/shell-ai/internal/tools"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// shellBuiltins are the builtins and keywords that are run by the shell
+// itself rather than found on $PATH
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "alias": true, "bg": true, "bind": true,
+	"break": true, "builtin": true, "cd": true, "command": true, "complete": true,
+	"continue": true, "declare": true, "dirs": true, "disown": true, "echo": true,
+	"enable": true, "eval": true, "exec": true, "exit": true, "export": true,
+	"false": true, "fc": true, "fg": true, "getopts": true, "hash": true,
+	"help": true, "history": true, "jobs": true, "kill": true, "let": true,
+	"local": true, "logout": true, "mapfile": true, "popd": true, "printf": true,
+	"pushd": true, "pwd": true, "read": true, "readarray": true, "readonly": true,
+	"return": true, "set": true, "shift": true, "shopt": true, "source": true,
+	"suspend": true, "test": true, "times": true, "trap": true, "true": true,
+	"type": true, "typeset": true, "ulimit": true, "umask": true, "unalias": true,
+	"unset": true, "wait": true,
+}
+
+// validateSuggestions drops the suggestions that are not valid syntax for the
+// shell and records the binaries each remaining suggestion runs or requires
+// that are not in the index. It returns the valid suggestions and how many were
+// dropped. The syntax is not checked for shells that cannot be parsed, such as
+// fish and zsh.
+func validateSuggestions(suggestions []Suggestion, sh *shell.Shell, index *tools.Index) ([]Suggestion, int) {
+	variant, ok := sh.Variant()
+	if !ok {
+		for i := range suggestions {
+			suggestions[i].Missing = missingRequired(nil, suggestions[i].Requires, index)
+		}
+		return suggestions, 0
+	}
+
+	parser := syntax.NewParser(syntax.Variant(variant))
+	valid := make([]Suggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		file, err := parser.Parse(strings.NewReader(suggestion.Command), "")
+		if err != nil {
+			continue
+		}
+		suggestion.Missing = missingRequired(missingBinaries(file, index), suggestion.Requires, index)
+		valid = append(valid, suggestion)
+	}
+	return valid, len(suggestions) - len(valid)
+}
+
+// missingBinaries returns the commands run by a script that are neither shell
+// builtins, functions defined by the script nor in the index. Commands whose
+// name is only known at run time, such as "$EDITOR", are not checked.
+func missingBinaries(file *syntax.File, index *tools.Index) []string {
+	functions := make(map[string]bool)
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if decl, ok := node.(*syntax.FuncDecl); ok {
+			functions[decl.Name.Value] = true
+		}
+		return true
+	})
+
+	var missing []string
+	seen := make(map[string]bool)
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		name := call.Args[0].Lit()
+		if name == "" || seen[name] || shellBuiltins[name] || functions[name] {
+			return true
+		}
+		seen[name] = true
+		if !index.Has(name) {
+			missing = append(missing, name)
+		}
+		return true
+	})
+	return missing
+}
+
+// missingRequired adds the binaries the LLM reported a command requires that
+// are not in the index to the missing ones
+func missingRequired(missing, requires []string, index *tools.Index) []string {
+	for _, name := range requires {
+		if name == "" || shellBuiltins[name] || index.Has(name) || slices.Contains(missing, name) {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// rankSuggestions moves the suggestions that need binaries which are not
+// installed after the ones that can run, keeping the order otherwise
+func rankSuggestions(suggestions []Suggestion) {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return len(suggestions[i].Missing) == 0 && len(suggestions[j].Missing) > 0
+	})
+}
@@ -0,0 +1,105 @@
+package suggestions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwswj/shell-ai/internal/parser"
+	"github.com/jwswj/shell-ai/internal/shell"
+	"github.com/jwswj/shell-ai/internal/tools"
+)
+
+func TestValidateSuggestions(t *testing.T) {
+	// Only the binaries in a temporary directory are installed
+	dir := t.TempDir()
+	for _, name := range []string{"ls", "grep", "find"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	index := tools.Scan(dir)
+
+	tests := []struct {
+		command     string
+		wantValid   bool
+		wantMissing []string
+	}{
+		{command: "ls -la | grep go", wantValid: true},
+		{command: "cd /tmp && ls", wantValid: true},
+		{command: "f() { ls; }; f", wantValid: true},
+		{command: `"$EDITOR" notes.txt`, wantValid: true},
+		{command: "find . -name '*.go' | xargs wc -l", wantValid: true, wantMissing: []string{"xargs"}},
+		{command: "fd -e go && fd -e md", wantValid: true, wantMissing: []string{"fd"}},
+		{command: `echo "unterminated`},
+		{command: "ls |"},
+		{command: "if true; then ls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			valid, dropped := validateSuggestions([]Suggestion{{CommandResponse: parser.CommandResponse{Command: tt.command}}}, &shell.Shell{Name: "bash"}, index)
+			if tt.wantValid != (dropped == 0) {
+				t.Fatalf("validateSuggestions() dropped %d, want valid %t", dropped, tt.wantValid)
+			}
+			if !tt.wantValid {
+				return
+			}
+			if got := strings.Join(valid[0].Missing, "|"); got != strings.Join(tt.wantMissing, "|") {
+				t.Errorf("Missing = %v, want %v", valid[0].Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestValidateSuggestionsUnparsableShell(t *testing.T) {
+	// Fish and zsh syntax is not checked
+	tests := []struct {
+		shell   string
+		command string
+	}{
+		{shell: "fish", command: "set files (ls)"},
+		{shell: "zsh", command: `print -l ${(f)"$(ls)"}`},
+		{shell: "zsh", command: "for f (*.txt) echo $f"},
+	}
+
+	for _, tt := range tests {
+		suggestions := []Suggestion{{CommandResponse: parser.CommandResponse{Command: tt.command}}}
+		valid, dropped := validateSuggestions(suggestions, &shell.Shell{Name: tt.shell}, tools.Scan(""))
+		if dropped != 0 || len(valid) != 1 {
+			t.Errorf("validateSuggestions(%q) for %s = %v, %d, want the suggestion kept", tt.command, tt.shell, valid, dropped)
+		}
+	}
+}
+
+func TestRankSuggestions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "find"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write find: %v", err)
+	}
+	index := tools.Scan(dir)
+
+	suggestions := []Suggestion{
+		{CommandResponse: parser.CommandResponse{Command: "fd -e go"}},
+		{CommandResponse: parser.CommandResponse{Command: "find . -name '*.go'"}},
+		{CommandResponse: parser.CommandResponse{Command: "find . -name '*.go' -print0", Requires: []string{"find", "xargs"}}},
+		{CommandResponse: parser.CommandResponse{Command: "find . -name '*.go' -type f"}},
+	}
+	valid, _ := validateSuggestions(suggestions, &shell.Shell{Name: "bash"}, index)
+	rankSuggestions(valid)
+
+	var got []string
+	for _, suggestion := range valid {
+		got = append(got, suggestion.Command+" "+strings.Join(suggestion.Missing, ","))
+	}
+	want := []string{
+		"find . -name '*.go' ",
+		"find . -name '*.go' -type f ",
+		"fd -e go fd",
+		"find . -name '*.go' -print0 xargs",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("rankSuggestions() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Package tokens estimates how many tokens a text takes for a model, so the
+// context sent along with a prompt fits the model's context window.
+//
+// The estimate follows how byte pair encoding tokenizers work: text is split
+// into words, numbers, punctuation and whitespace the way the tokenizer splits
+// it before merging, and each piece is counted by how many characters a token
+// of that model family covers on average. Budgets built on the estimate should
+// leave some room, as it can be off by a few tokens per line.
+package tokens
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encoding approximates the tokenizer of a family of models
+type Encoding struct {
+	// Name identifies the tokenizer, such as "cl100k"
+	Name string
+
+	// charsPerToken is how many characters of a word a token covers on
+	// average
+	charsPerToken float64
+
+	// digitsPerToken is how many digits are merged into one token
+	digitsPerToken int
+}
+
+// Encodings of the model families
+var (
+	CL100K        = Encoding{Name: "cl100k", charsPerToken: 4, digitsPerToken: 3}
+	O200K         = Encoding{Name: "o200k", charsPerToken: 4.5, digitsPerToken: 3}
+	Llama3        = Encoding{Name: "llama3", charsPerToken: 4, digitsPerToken: 3}
+	Claude        = Encoding{Name: "claude", charsPerToken: 3.5, digitsPerToken: 1}
+	SentencePiece = Encoding{Name: "sentencepiece", charsPerToken: 3.2, digitsPerToken: 1}
+)
+
+// model describes the tokenizer and context window of models whose name
+// contains a pattern, or starts with it if prefix is set
+type model struct {
+	pattern  string
+	prefix   bool
+	encoding Encoding
+	window   int
+}
+
+// models are matched in order against lower case model names, so more
+// specific patterns come first
+var models = []model{
+	{pattern: "gpt-4.1", encoding: O200K, window: 1047576},
+	{pattern: "gpt-4o", encoding: O200K, window: 128000},
+	{pattern: "gpt-4-turbo", encoding: CL100K, window: 128000},
+	{pattern: "gpt-4-32k", encoding: CL100K, window: 32768},
+	{pattern: "gpt-4", encoding: CL100K, window: 8192},
+	{pattern: "gpt-3.5-turbo", encoding: CL100K, window: 16385},
+	{pattern: "o1", prefix: true, encoding: O200K, window: 200000},
+	{pattern: "o3", prefix: true, encoding: O200K, window: 200000},
+	{pattern: "o4", prefix: true, encoding: O200K, window: 200000},
+	{pattern: "claude", encoding: Claude, window: 200000},
+	{pattern: "llama-3.1", encoding: Llama3, window: 131072},
+	{pattern: "llama-3.2", encoding: Llama3, window: 131072},
+	{pattern: "llama-3.3", encoding: Llama3, window: 131072},
+	{pattern: "llama3.1", encoding: Llama3, window: 131072},
+	{pattern: "llama3.2", encoding: Llama3, window: 131072},
+	{pattern: "llama3.3", encoding: Llama3, window: 131072},
+	{pattern: "llama-3", encoding: Llama3, window: 8192},
+	{pattern: "llama3", encoding: Llama3, window: 8192},
+	{pattern: "llama", encoding: SentencePiece, window: 4096},
+	{pattern: "mixtral", encoding: SentencePiece, window: 32768},
+	{pattern: "mistral", encoding: SentencePiece, window: 32768},
+	{pattern: "gemma", encoding: SentencePiece, window: 8192},
+	{pattern: "qwen", encoding: CL100K, window: 32768},
+	{pattern: "deepseek", encoding: CL100K, window: 65536},
+}
+
+// defaultWindow is the context window assumed for unknown models
+const defaultWindow = 8192
+
+// maxBudget caps the automatic context budget, so long sessions do not make
+// every request slow and expensive on models with large windows
+const maxBudget = 4000
+
+// lookup returns the description of a model, and false if it is unknown
+func lookup(name string) (model, bool) {
+	name = strings.ToLower(name)
+	for _, m := range models {
+		if m.prefix && strings.HasPrefix(name, m.pattern) || !m.prefix && strings.Contains(name, m.pattern) {
+			return m, true
+		}
+	}
+	return model{}, false
+}
+
+// ForModel returns the encoding of a model, CL100K if it is unknown
+func ForModel(name string) Encoding {
+	if m, ok := lookup(name); ok {
+		return m.encoding
+	}
+	return CL100K
+}
+
+// ContextWindow returns how many tokens a model accepts, including its
+// response
+func ContextWindow(name string) int {
+	if m, ok := lookup(name); ok {
+		return m.window
+	}
+	return defaultWindow
+}
+
+// DefaultBudget returns how many tokens of context to send to a model: a
+// quarter of its context window, leaving room for the instructions and the
+// response, and at most maxBudget
+func DefaultBudget(name string) int {
+	budget := ContextWindow(name) / 4
+	if budget > maxBudget {
+		budget = maxBudget
+	}
+	return budget
+}
+
+// piecePattern splits text the way byte pair encoding tokenizers do before
+// merging: contractions, words with their leading space or punctuation mark,
+// numbers, runs of punctuation, and whitespace
+var piecePattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}+| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// Count estimates how many tokens a text takes
+func (e Encoding) Count(text string) int {
+	count := 0
+	for _, piece := range piecePattern.FindAllString(text, -1) {
+		count += e.countPiece(piece)
+	}
+	return count
+}
+
+// countPiece estimates how many tokens a piece of pre-split text takes
+func (e Encoding) countPiece(piece string) int {
+	first, _ := utf8.DecodeRuneInString(piece)
+	switch {
+	case unicode.IsSpace(first) && strings.TrimSpace(piece) == "":
+		return 1
+	case unicode.IsNumber(first):
+		return ceilDiv(utf8.RuneCountInString(piece), e.digitsPerToken)
+	}
+
+	// Text outside ASCII is split into far smaller tokens, often one per
+	// character or less
+	ascii, other := 0, 0
+	for _, r := range piece {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+
+	if !unicode.IsLetter(first) {
+		if strings.IndexFunc(piece, unicode.IsLetter) < 0 {
+			// Runs of punctuation merge into tokens of about two characters
+			return ceilDiv(ascii, 2) + other
+		}
+
+		// The space or punctuation mark before a word merges into its first
+		// token
+		if first < utf8.RuneSelf {
+			ascii--
+		} else {
+			other--
+		}
+	}
+	if ascii == 0 {
+		return other
+	}
+
+	// Common short words are a single token
+	return max(1, int(math.Round(float64(ascii)/e.charsPerToken))) + other
+}
+
+// ceilDiv divides rounding up
+func ceilDiv(n, d int) int {
+	if n <= 0 {
+		return 0
+	}
+	return (n + d - 1) / d
+}
@@ -0,0 +1,82 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForModel(t *testing.T) {
+	tests := []struct {
+		model    string
+		encoding Encoding
+		window   int
+	}{
+		{model: "gpt-4o-mini", encoding: O200K, window: 128000},
+		{model: "gpt-3.5-turbo", encoding: CL100K, window: 16385},
+		{model: "o3-mini", encoding: O200K, window: 200000},
+		{model: "claude-3-5-haiku-latest", encoding: Claude, window: 200000},
+		{model: "llama-3.3-70b-versatile", encoding: Llama3, window: 131072},
+		{model: "llama3.2:latest", encoding: Llama3, window: 131072},
+		{model: "meta-llama/Meta-Llama-3-8B-Instruct", encoding: Llama3, window: 8192},
+		{model: "mixtral-8x7b-32768", encoding: SentencePiece, window: 32768},
+		{model: "my-finetune", encoding: CL100K, window: defaultWindow},
+		{model: "", encoding: CL100K, window: defaultWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := ForModel(tt.model); got.Name != tt.encoding.Name {
+				t.Errorf("ForModel(%q) = %s, want %s", tt.model, got.Name, tt.encoding.Name)
+			}
+			if got := ContextWindow(tt.model); got != tt.window {
+				t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.window)
+			}
+		})
+	}
+}
+
+func TestDefaultBudget(t *testing.T) {
+	if got := DefaultBudget("my-finetune"); got != defaultWindow/4 {
+		t.Errorf("DefaultBudget() = %d, want a quarter of the window", got)
+	}
+	if got := DefaultBudget("gpt-4o"); got != maxBudget {
+		t.Errorf("DefaultBudget() = %d, want it capped at %d", got, maxBudget)
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "hello world", want: 2},
+		{text: "ls -la /var/log", want: 6},
+		{text: "12345678", want: 3},
+		{text: "   \n", want: 1},
+		{text: "日本語", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := CL100K.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCountIsCloseToRealTokenizers(t *testing.T) {
+	// Byte pair encoding splits a typical line of ls -l output into about 20
+	// cl100k tokens
+	line := "-rw-r--r--  1 user staff  4096 Jan 12 10:31 main.go\n"
+	text := strings.Repeat(line, 100)
+
+	got := CL100K.Count(text)
+	if got < 1500 || got > 3000 {
+		t.Errorf("Count() = %d, want about 2000", got)
+	}
+
+	// Models with smaller vocabularies take more tokens for the same text
+	if SentencePiece.Count(text) <= O200K.Count(text) {
+		t.Errorf("SentencePiece.Count() = %d, want more than O200K.Count() = %d", SentencePiece.Count(text), O200K.Count(text))
+	}
+}
@@ -0,0 +1,200 @@
+// Package tools indexes the binaries on $PATH, so suggestions can be limited
+// to the tools that are installed.
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/cache"
+)
+
+// knownTools are the tools models commonly suggest. The system prompt says
+// which of them are installed, so the model neither misses nor assumes them.
+var knownTools = []string{
+	"fd", "find", "rg", "ag", "grep", "jq", "yq", "fzf", "bat", "eza", "exa",
+	"tree", "ncdu", "dust", "duf", "htop", "btop", "lsof", "ss", "netstat", "ip",
+	"ifconfig", "curl", "wget", "http", "nc", "nmap", "dig", "rsync", "scp",
+	"tar", "zip", "unzip", "7z", "xz", "zstd", "gawk", "gsed", "gfind", "parallel",
+	"watch", "timeout", "tmux", "git", "gh", "docker", "podman", "kubectl", "helm",
+	"aws", "gcloud", "az", "terraform", "ffmpeg", "convert", "magick", "pandoc",
+	"sqlite3", "psql", "mysql", "python3", "node", "go", "cargo", "systemctl",
+	"journalctl", "pbcopy", "xclip", "wl-copy",
+}
+
+// Index is the set of binaries found on $PATH
+type Index struct {
+	// Path is the value of $PATH the index was built for
+	Path string `json:"path"`
+
+	// ModTimes are the modification times of the $PATH directories, which
+	// change when binaries are installed or removed
+	ModTimes map[string]time.Time `json:"mod_times"`
+
+	Binaries []string `json:"binaries"`
+
+	set map[string]bool
+}
+
+var (
+	mu      sync.Mutex
+	current *Index
+)
+
+// Load returns the index of the binaries on $PATH. The index is kept in memory
+// and in the user's cache directory, and is rebuilt when $PATH or any of its
+// directories change.
+func Load() *Index {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := os.Getenv("PATH")
+	if current != nil && current.valid(path) {
+		return current
+	}
+
+	cachePath := cache.Path("tools.json")
+	if index, err := loadCache(cachePath); err == nil && index.valid(path) {
+		current = index
+		return current
+	}
+
+	current = Scan(path)
+	if cachePath != "" {
+		// A failure to cache only means scanning again next time
+		_ = cache.Save(cachePath, current)
+	}
+	return current
+}
+
+// Scan indexes the executables in the directories of a $PATH value
+func Scan(path string) *Index {
+	index := &Index{
+		Path:     path,
+		ModTimes: make(map[string]time.Time),
+		set:      make(map[string]bool),
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		index.ModTimes[dir] = info.ModTime()
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if name, ok := executableName(dir, entry); ok && !index.set[name] {
+				index.set[name] = true
+				index.Binaries = append(index.Binaries, name)
+			}
+		}
+	}
+
+	sort.Strings(index.Binaries)
+	return index
+}
+
+// executableName returns the name a directory entry is run by, and whether it
+// is an executable file
+func executableName(dir string, entry os.DirEntry) (string, bool) {
+	name := entry.Name()
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".exe" && ext != ".bat" && ext != ".cmd" && ext != ".com" {
+			return "", false
+		}
+		return strings.TrimSuffix(name, filepath.Ext(name)), true
+	}
+
+	// Follow symlinks, which is how most package managers install binaries
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return "", false
+	}
+	return name, true
+}
+
+// valid reports whether the index is up to date for a $PATH value
+func (i *Index) valid(path string) bool {
+	if i.Path != path {
+		return false
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		info, err := os.Stat(dir)
+		cached, ok := i.ModTimes[dir]
+		if (err == nil) != ok || (ok && !info.ModTime().Equal(cached)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Has reports whether a binary is installed. Names holding a path separator
+// are looked up directly rather than on $PATH.
+func (i *Index) Has(name string) bool {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		info, err := os.Stat(name)
+		return err == nil && !info.IsDir()
+	}
+	return i.set[name]
+}
+
+// Summary describes which of the tools models commonly suggest are installed,
+// along with the tools the prompt mentions, for the system prompt
+func (i *Index) Summary(prompt string) string {
+	var installed, missing []string
+	seen := make(map[string]bool)
+	for _, name := range knownTools {
+		seen[name] = true
+		if i.Has(name) {
+			installed = append(installed, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	// Tools the user asks about are relevant even if they are not well known
+	for _, word := range strings.FieldsFunc(prompt, func(r rune) bool {
+		return !(r == '-' || r == '_' || r == '.' || r == '+' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9'))
+	}) {
+		if !seen[word] && len(word) > 1 && i.Has(word) {
+			seen[word] = true
+			installed = append(installed, word)
+		}
+	}
+
+	summary := fmt.Sprintf("Installed commands include: %s.", strings.Join(installed, ", "))
+	if len(missing) > 0 {
+		summary += fmt.Sprintf(" These commands are not installed, do not use them: %s.", strings.Join(missing, ", "))
+	}
+	return summary
+}
+
+// loadCache loads a cached index
+func loadCache(path string) (*Index, error) {
+	var index Index
+	if err := cache.Load(path, &index); err != nil {
+		return nil, err
+	}
+	index.set = make(map[string]bool, len(index.Binaries))
+	for _, name := range index.Binaries {
+		index.set[name] = true
+	}
+	return &index, nil
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwswj/shell-ai/internal/cache"
+)
+
+// writeExecutable creates a file in dir with the given mode
+func writeExecutable(t *testing.T, dir, name string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeExecutable(t, first, "rg", 0755)
+	writeExecutable(t, first, "notes.txt", 0644)
+	writeExecutable(t, second, "jq", 0755)
+	if err := os.Symlink(filepath.Join(first, "rg"), filepath.Join(second, "ripgrep")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(second, "lib"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	index := Scan(strings.Join([]string{first, "", filepath.Join(first, "missing"), second}, string(os.PathListSeparator)))
+	if got := strings.Join(index.Binaries, ","); got != "jq,rg,ripgrep" {
+		t.Errorf("Binaries = %q, want jq,rg,ripgrep", got)
+	}
+	for name, want := range map[string]bool{"rg": true, "ripgrep": true, "jq": true, "notes.txt": false, "lib": false, "fd": false} {
+		if got := index.Has(name); got != want {
+			t.Errorf("Has(%q) = %t, want %t", name, got, want)
+		}
+	}
+	if !index.Has(filepath.Join(first, "notes.txt")) {
+		t.Error("Has() = false for an existing path, want true")
+	}
+}
+
+func TestValid(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, dir, "rg", 0755)
+	index := Scan(dir)
+
+	if !index.valid(dir) {
+		t.Error("valid() = false right after scanning")
+	}
+	if index.valid(dir + string(os.PathListSeparator) + t.TempDir()) {
+		t.Error("valid() = true for a different $PATH")
+	}
+
+	// Installing a binary changes the modification time of the directory
+	later := time.Now().Add(time.Minute)
+	writeExecutable(t, dir, "fd", 0755)
+	if err := os.Chtimes(dir, later, later); err != nil {
+		t.Fatalf("Failed to change times: %v", err)
+	}
+	if index.valid(dir) {
+		t.Error("valid() = true after a binary was installed")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, dir, "rg", 0755)
+	t.Setenv("PATH", dir)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if !Load().Has("rg") {
+		t.Fatal("Load() does not have rg")
+	}
+
+	// The index is cached on disk
+	cached, err := loadCache(cache.Path("tools.json"))
+	if err != nil {
+		t.Fatalf("loadCache() error = %v", err)
+	}
+	if !cached.valid(dir) || !cached.Has("rg") {
+		t.Errorf("loadCache() = %+v, want the index of %s", cached, dir)
+	}
+
+	// A new $PATH is scanned again
+	other := t.TempDir()
+	writeExecutable(t, other, "fd", 0755)
+	t.Setenv("PATH", other)
+	if index := Load(); index.Has("rg") || !index.Has("fd") {
+		t.Errorf("Load() = %v, want the binaries of the new $PATH", index.Binaries)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"find", "grep", "jq", "mytool"} {
+		writeExecutable(t, dir, name, 0755)
+	}
+	index := Scan(dir)
+
+	summary := index.Summary("use mytool to count the files")
+	installed, missing, _ := strings.Cut(summary, " These commands are not installed")
+	if installed != "Installed commands include: find, grep, jq, mytool." {
+		t.Errorf("Summary() installed = %q", installed)
+	}
+	for _, name := range []string{"fd", "rg", "bat"} {
+		if !strings.Contains(missing, " "+name+",") {
+			t.Errorf("Summary() missing = %q, want it to list %s", missing, name)
+		}
+	}
+}